@@ -0,0 +1,853 @@
+// Package analysis reports outdated, deprecated, retracted and vulnerable dependencies of go
+// modules. It does not depend on any logging or metrics library, see Analyze for the entry point.
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+	"gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// Analyzer - analyze dependencies of go projects, safe for concurrent use
+type Analyzer struct {
+	config Config
+	proxy  *ProxyClient
+	vulns  *VulnDB
+	cache  *mirrorCache
+}
+
+// New - create Analyzer from given configuration
+func New(config Config) (*Analyzer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	proxy, err := NewProxyClient(&config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := &Analyzer{
+		config: config,
+		proxy:  proxy,
+	}
+	analyzer.vulns = NewVulnDB(&analyzer.config.Vulnerabilities, analyzer.config.Logger)
+	analyzer.cache = newMirrorCache(&analyzer.config.Cache, analyzer.config.Logger)
+	return analyzer, nil
+}
+
+// Analyze - analyze given source with default settings
+func Analyze(ctx context.Context, source Source) (*Report, error) {
+	analyzer, err := New(Config{})
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.Analyze(ctx, source)
+}
+
+// Refresh - reload vulnerability database if it changed since last analysis
+func (a *Analyzer) Refresh() error {
+	if a.vulns == nil {
+		return nil
+	}
+	return a.vulns.Refresh()
+}
+
+// Prune - remove cached mirrors of repositories not in given urls, then least recently used ones
+// until cache fits its size limit
+func (a *Analyzer) Prune(urls []string) error {
+	if a.cache == nil {
+		return nil
+	}
+	return a.cache.prune(urls)
+}
+
+// Analyze - analyze a single project, report is returned even when analysis fails
+func (a *Analyzer) Analyze(ctx context.Context, source Source) (*Report, error) {
+	report := newReport(source.URL, time.Now())
+	report.Ref = source.Ref
+	if source.Logger == nil {
+		source.Logger = a.config.Logger
+	}
+	if err := a.config.ValidateSource(&source); err != nil {
+		return report.fail(err), err
+	}
+	if err := a.analyzeProject(ctx, &source, report); err != nil {
+		return report.fail(err), err
+	}
+
+	rules := append(append([]IgnoreRule{}, source.Ignore...), a.config.Ignore...)
+	expired := applyIgnoreRules(rules, report.Dependencies, report.Time)
+	for _, cRule := range expired {
+		source.Logger.Warnf("ignore rule %s%s expired on %s: %s", cRule.Path, cRule.Regex, cRule.Until, cRule.Reason)
+	}
+	report.ExpiredIgnoreRules = len(expired)
+	report.Policies = evaluatePolicies(a.config.Policies, report.Dependencies, report.Time)
+	for _, cDep := range report.Dependencies {
+		if cDep.Error != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", cDep.Path, cDep.Error.Err))
+		}
+	}
+	return report, nil
+}
+
+// commitHash - matches refs given as full commit hash
+var commitHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// abbreviatedHash - matches refs looking like a commit hash too short to be fetched, when no
+// branch nor tag has this name
+var abbreviatedHash = regexp.MustCompile(`^[0-9a-f]{7,39}$`)
+
+// resolveRef - remote reference matching ref of source, branch targeted by remote HEAD when
+// ref is empty, nil for commit hashes or when remote does not advertise its HEAD
+func (a *Analyzer) resolveRef(ctx context.Context, source *Source) (*plumbing.Reference, error) {
+	if commitHash.MatchString(source.Ref) {
+		return nil, nil
+	}
+	refs, err := listRefs(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if isRefPattern(source.Ref) {
+		return latestTag(refs, source.Ref)
+	}
+	names := []plumbing.ReferenceName{
+		plumbing.ReferenceName(source.Ref),
+		plumbing.NewBranchReferenceName(source.Ref),
+		plumbing.NewTagReferenceName(source.Ref),
+	}
+	if source.Ref == "" {
+		// single branch clone of HEAD only looks for master, default branch is given explicitly
+		names = nil
+		for _, cRef := range refs {
+			if cRef.Name() == plumbing.HEAD && cRef.Type() == plumbing.SymbolicReference {
+				names = append(names, cRef.Target())
+			}
+		}
+	}
+	for _, cName := range names {
+		for _, cRef := range refs {
+			if cRef.Name() == cName {
+				return cRef, nil
+			}
+		}
+	}
+	if source.Ref == "" {
+		return nil, nil
+	}
+	if abbreviatedHash.MatchString(source.Ref) {
+		return nil, errors.Errorf(
+			"no branch nor tag named %s, abbreviated commit hashes are not supported, give the full hash, refs/heads/%[1]s or refs/tags/%[1]s",
+			source.Ref,
+		)
+	}
+	return nil, errors.Errorf("no branch nor tag named %s", source.Ref)
+}
+
+// listRefs - references advertised by remote of source, until ctx is done
+func listRefs(ctx context.Context, source *Source) ([]*plumbing.Reference, error) {
+	type listing struct {
+		refs []*plumbing.Reference
+		err  error
+	}
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{source.URL},
+	})
+	// listing can not be cancelled, a hanging remote only keeps this goroutine
+	done := make(chan listing, 1)
+	go func() {
+		refs, err := remote.List(&git.ListOptions{Auth: source.Auth})
+		done <- listing{refs: refs, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "unable to list remote references")
+	case result := <-done:
+		if result.err != nil {
+			return nil, errors.Wrap(result.err, "unable to list remote references")
+		}
+		return result.refs, nil
+	}
+}
+
+// isRefPattern - tells if ref is a glob selecting the highest matching semver tag
+func isRefPattern(ref string) bool {
+	return strings.ContainsAny(ref, "*?[")
+}
+
+// latestTag - highest semver tag matching given glob, pre-releases are only selected when
+// no release matches
+func latestTag(refs []*plumbing.Reference, pattern string) (*plumbing.Reference, error) {
+	var latest *plumbing.Reference
+	for _, cRef := range refs {
+		if !cRef.Name().IsTag() {
+			continue
+		}
+		tag := cRef.Name().Short()
+		if ok, _ := path.Match(pattern, tag); !ok || !semver.IsValid(tag) {
+			continue
+		}
+		if latest == nil {
+			latest = cRef
+			continue
+		}
+		current := latest.Name().Short()
+		release, currentRelease := semver.Prerelease(tag) == "", semver.Prerelease(current) == ""
+		if release != currentRelease {
+			if release {
+				latest = cRef
+			}
+			continue
+		}
+		if semver.Compare(tag, current) > 0 {
+			latest = cRef
+		}
+	}
+	if latest == nil {
+		return nil, errors.Errorf("no semver tag matches %s", pattern)
+	}
+	return latest, nil
+}
+
+// getRepository - clone ref of source in given directory, returns name of checked out reference
+// and hash of checked out commit
+func (a *Analyzer) getRepository(ctx context.Context, source *Source, dir string) (string, string, error) {
+	source.Logger.Debugf("cloning repository")
+
+	ctx, cancel := context.WithTimeout(ctx, a.config.CloneTimeout)
+	defer cancel()
+	ref, err := a.resolveRef(ctx, source)
+	if err != nil {
+		source.Logger.Errorf("%s", err.Error())
+		return "", "", err
+	}
+	if a.cache != nil {
+		name, commit, err := a.cache.checkout(ctx, source, ref, dir)
+		if err != nil {
+			source.Logger.Errorf("%s", err.Error())
+		}
+		return name, commit, err
+	}
+	// references outside of branches and tags, such as pull requests, can not be cloned alone
+	if ref != nil && !ref.Name().IsBranch() && !ref.Name().IsTag() {
+		if err := a.fetchRef(ctx, source, dir, ref); err != nil {
+			source.Logger.Errorf("%s", err.Error())
+			return "", "", err
+		}
+		return ref.Name().String(), ref.Hash().String(), nil
+	}
+	options := &git.CloneOptions{
+		URL:               source.URL,
+		SingleBranch:      true,
+		Depth:             1,
+		Auth:              source.Auth,
+		RecurseSubmodules: git.NoRecurseSubmodules,
+	}
+	if ref != nil {
+		options.ReferenceName = ref.Name()
+	}
+	// arbitrary commits can not be fetched alone, whole history is needed
+	isCommit := commitHash.MatchString(source.Ref)
+	if isCommit {
+		options.SingleBranch = false
+		options.Depth = 0
+	}
+	repository, err := git.PlainCloneContext(ctx, dir, false, options)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to checkout")
+		source.Logger.Errorf("%s", err.Error())
+		return "", "", err
+	}
+
+	if isCommit {
+		worktree, err := repository.Worktree()
+		if err == nil {
+			err = worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(source.Ref)})
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "unable to checkout commit %s", source.Ref)
+			source.Logger.Errorf("%s", err.Error())
+			return "", "", err
+		}
+		return "", source.Ref, nil
+	}
+	commit := a.getCommit(source, dir)
+	if ref != nil {
+		return ref.Name().String(), commit, nil
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return "", commit, nil
+	}
+	return head.Name().String(), commit, nil
+}
+
+// fetchRef - fetch given reference alone in a new repository of dir and check it out, like
+// mirrors do for references outside of branches and tags
+func (a *Analyzer) fetchRef(ctx context.Context, source *Source, dir string, ref *plumbing.Reference) error {
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to checkout")
+	}
+	_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{source.URL},
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to checkout")
+	}
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:%[1]s", ref.Name()))},
+		Depth:      1,
+		Auth:       source.Auth,
+		Tags:       git.NoTags,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to fetch %s", ref.Name())
+	}
+	worktree, err := repository.Worktree()
+	if err == nil {
+		err = worktree.Checkout(&git.CheckoutOptions{Hash: ref.Hash()})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to checkout %s", ref.Name())
+	}
+	return nil
+}
+
+// getCommit - hash of commit checked out in given directory, empty when not a git repository
+func (a *Analyzer) getCommit(source *Source, dir string) string {
+	repository, err := git.PlainOpen(dir)
+	if err != nil {
+		source.Logger.Debugf("unable to open git repository: %s", err)
+		return ""
+	}
+	head, err := repository.Head()
+	if err != nil {
+		source.Logger.Debugf("unable to resolve git HEAD: %s", err)
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// getToolchain - version of go toolchain selected to analyze given directory
+func (a *Analyzer) getToolchain(ctx context.Context, source *Source, dir string) string {
+	ctx, cancel := context.WithTimeout(ctx, a.config.ListTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", "env", "GOVERSION")
+	cmd.Dir = dir
+	cmd.Env = a.config.Proxy.Environ()
+	content, err := cmd.Output()
+	if err != nil {
+		source.Logger.Warnf("unable to get go toolchain version: %s", err)
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func (a *Analyzer) getModules(ctx context.Context, source *Source, dir string, project string) ([]ModulePublic, error) {
+	source.Logger.Debugf("extracting go modules for %s", project)
+	return a.listModules(ctx, source, dir, "-versions", "-u", "-mod=mod", "-m", "-json", project)
+}
+
+// listModules - run go list with given arguments and parse its json output
+func (a *Analyzer) listModules(ctx context.Context, source *Source, dir string, args ...string) ([]ModulePublic, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.config.ListTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", append([]string{"list"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = a.config.Proxy.Environ()
+	content, err := cmd.Output()
+	if err != nil {
+		if exerr, ok := err.(*exec.ExitError); ok {
+			source.Logger.Errorf("%s", string(exerr.Stderr))
+		}
+		err = errors.Wrap(err, "unable to run go analysis")
+		source.Logger.Errorf("%s", err.Error())
+		return nil, err
+	}
+
+	jsonStr := string(content)
+	jsonStr = strings.ReplaceAll(jsonStr, "}\n{", "},\n{")
+	jsonStr = fmt.Sprintf("[%s]", jsonStr)
+	modules := []ModulePublic{}
+	err = json.Unmarshal([]byte(jsonStr), &modules)
+	if err != nil {
+		err = errors.Wrap(err, "unable to parse go list output")
+		source.Logger.Errorf("%s", err.Error())
+		return nil, err
+	}
+
+	return modules, nil
+}
+
+// refreshModules - dependencies of a previous analysis with available versions, updates,
+// deprecations and retractions queried again, module graph of main module is not resolved
+func (a *Analyzer) refreshModules(ctx context.Context, source *Source, previous []ModulePublic) ([]ModulePublic, error) {
+	source.Logger.Debugf("refreshing upstream versions of %d dependencies", len(previous))
+	queries := []string{}
+	for _, cDep := range previous {
+		// local directory replacements have no upstream
+		if cDep.Version != "" {
+			queries = append(queries, cDep.Path+"@"+cDep.Version)
+		}
+	}
+	upstream := map[string]ModulePublic{}
+	if len(queries) != 0 {
+		args := append([]string{"-e", "-versions", "-u", "-mod=mod", "-m", "-json"}, queries...)
+		modules, err := a.listModules(ctx, source, source.Dir, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, cModule := range modules {
+			upstream[cModule.Path+"@"+cModule.Version] = cModule
+		}
+	}
+
+	deps := make([]ModulePublic, 0, len(previous))
+	for _, cDep := range previous {
+		// fields derived during analysis are computed again from scratch
+		dep := ModulePublic{
+			Path:      cDep.Path,
+			Version:   cDep.Version,
+			Time:      cDep.Time,
+			Indirect:  cDep.Indirect,
+			Dir:       cDep.Dir,
+			GoMod:     cDep.GoMod,
+			GoVersion: cDep.GoVersion,
+			Line:      cDep.Line,
+		}
+		if module, ok := upstream[cDep.Path+"@"+cDep.Version]; ok {
+			dep.Versions = module.Versions
+			dep.Update = module.Update
+			dep.Retracted = module.Retracted
+			dep.Deprecated = module.Deprecated
+			dep.Error = module.Error
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// fingerprint - digest of files defining module graph of given directory, empty without go.mod
+func fingerprint(dir string) string {
+	hash := sha256.New()
+	for _, cName := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+		content, err := os.ReadFile(filepath.Join(dir, cName))
+		if err != nil && cName == "go.mod" {
+			return ""
+		}
+		// missing files are distinguished from empty ones
+		fmt.Fprintf(hash, "%s %t %d\n", cName, err == nil, len(content))
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isUnchanged - tells if previous report was computed from same sources with same toolchain
+func isUnchanged(previous *Report, report *Report) bool {
+	return previous != nil && previous.Success && previous.SchemaVersion == ReportSchemaVersion &&
+		previous.Repository == report.Repository && previous.Ref == report.Ref &&
+		report.Commit != "" && previous.Commit == report.Commit &&
+		report.Fingerprint != "" && previous.Fingerprint == report.Fingerprint &&
+		previous.Toolchain == report.Toolchain
+}
+
+// resolveModules - main module, dependencies and replaced dependencies of source
+func (a *Analyzer) resolveModules(ctx context.Context, source *Source) (ModulePublic, []ModulePublic, []ModulePublic, error) {
+	var main ModulePublic
+	deps := []ModulePublic{}
+	replaces := []ModulePublic{}
+
+	modules, err := a.getModules(ctx, source, source.Dir, "all")
+	if err != nil {
+		return main, nil, nil, err
+	}
+
+	for _, cModule := range modules {
+		if cModule.Main {
+			main = cModule
+			break
+		}
+	}
+	lines, err := requireLines(main.GoMod)
+	if err != nil {
+		source.Logger.Warnf("unable to locate require directives in go.mod: %s", err)
+	}
+	// go.mod path made relative to repository root, checkout directory may be temporary
+	if rel, err := filepath.Rel(source.Dir, main.GoMod); err == nil {
+		main.GoMod = filepath.ToSlash(rel)
+	}
+
+	for _, cModule := range modules {
+		if cModule.Main {
+			continue
+		}
+
+		line := lines[cModule.Path]
+		if cModule.Replace != nil {
+			replaces = append(replaces, cModule)
+			cModule = *cModule.Replace
+		}
+		cModule.Line = line
+		deps = append(deps, cModule)
+	}
+	return main, deps, replaces, nil
+}
+
+// analyzeProject - fill given report with main module and analyzed dependencies of source
+func (a *Analyzer) analyzeProject(ctx context.Context, source *Source, report *Report) error {
+	var (
+		main     ModulePublic
+		deps     []ModulePublic
+		replaces []ModulePublic
+		err      error
+	)
+
+	source.Logger.Infof("analysing project")
+
+	if source.Dir == "" {
+		dir, err := os.MkdirTemp("", "git-checkout")
+		if err != nil {
+			err = errors.Wrap(err, "unable to create temp directory")
+			source.Logger.Errorf("%s", err.Error())
+			return err
+		}
+		defer utils.RemoveDir(dir)
+		if report.Reference, report.Commit, err = a.getRepository(ctx, source, dir); err != nil {
+			return err
+		}
+		source.Dir = dir
+	} else {
+		// go list gives absolute paths, which are made relative to given directory
+		dir, err := filepath.Abs(source.Dir)
+		if err != nil {
+			err = errors.Wrap(err, "unable to resolve project directory")
+			source.Logger.Errorf("%s", err.Error())
+			return err
+		}
+		source.Dir = dir
+		report.Commit = a.getCommit(source, source.Dir)
+	}
+
+	report.Toolchain = a.getToolchain(ctx, source, source.Dir)
+	report.Fingerprint = fingerprint(source.Dir)
+
+	// module graph only depends on go.mod and go.sum, upstream versions are all that may change
+	if previous := source.Previous; isUnchanged(previous, report) {
+		source.Logger.Infof("commit and go.mod unchanged since %s, refreshing upstream versions only", previous.Time.Format(time.RFC3339))
+		if deps, err = a.refreshModules(ctx, source, previous.Dependencies); err == nil {
+			report.Incremental = true
+			main = ModulePublic{Path: previous.Module, GoMod: previous.GoMod, GoVersion: previous.GoVersion}
+			replaces = previous.Replaces
+		} else {
+			source.Logger.Warnf("falling back to full analysis: %s", err)
+		}
+	}
+	if !report.Incremental {
+		if main, deps, replaces, err = a.resolveModules(ctx, source); err != nil {
+			return err
+		}
+	}
+
+	utils.RunParallel(ctx, a.config.DependencyWorkers, len(deps), func(ctx context.Context, idx int) {
+		a.analyzeDependency(ctx, source, &deps[idx])
+	})
+	thresholds := a.config.Staleness.Merge(source.Staleness)
+	for cIdx := range deps {
+		deps[cIdx].Staleness = thresholds.Classify(&deps[cIdx])
+	}
+	if a.vulns != nil && a.config.Vulnerabilities.Reachability && ctx.Err() == nil {
+		a.analyzeReachability(ctx, source, deps)
+	}
+	if err := ctx.Err(); err != nil {
+		err = errors.Wrap(err, "analysis interrupted")
+		source.Logger.Errorf("%s", err.Error())
+		return err
+	}
+	report.Module = main.Path
+	report.GoMod = main.GoMod
+	report.GoVersion = main.GoVersion
+	report.Dependencies = deps
+	report.Replaces = replaces
+	return nil
+}
+
+func (a *Analyzer) analyzeDependency(ctx context.Context, source *Source, module *ModulePublic) {
+	source.Logger.Debugf("analyzing dependency: %s", module.Path)
+	if module.Deprecated != "" {
+		module.DeprecatedBy = deprecationReplacement(module.Deprecated)
+		source.Logger.Debugf("dependency %s is deprecated: %s", module.Path, module.Deprecated)
+	}
+	if len(module.Retracted) != 0 {
+		source.Logger.Debugf("dependency %s@%s is retracted: %s", module.Path, module.Version, strings.Join(module.Retracted, "; "))
+	}
+	if a.vulns != nil {
+		module.Vulns = a.vulns.Match(module.Path, module.Version)
+		for _, cVuln := range module.Vulns {
+			source.Logger.Debugf("dependency %s@%s is affected by %s", module.Path, module.Version, cVuln.ID)
+		}
+	}
+	module.NextMajor = a.getNextMajor(ctx, source, module)
+	a.resolveUpdate(ctx, source, module)
+	module.Cadence = a.getReleaseCadence(ctx, source, module)
+}
+
+// resolveUpdate - find version following current one and semantic distance to latest version
+func (a *Analyzer) resolveUpdate(ctx context.Context, source *Source, module *ModulePublic) {
+	commitTime, pseudoBase, isPseudo := pseudoVersion(module.Version)
+	module.Pseudo = isPseudo
+	module.Incompatible = semver.Build(module.Version) == "+incompatible"
+	if module.Pseudo {
+		module.Time = &commitTime
+	}
+	if module.Update == nil {
+		module.NextUpdate = nil
+		module.Lag = versionLag(module.Version, "", nil)
+		return
+	}
+
+	newer, known := a.getNewerVersions(ctx, source, module, isPseudo && pseudoBase == "")
+	if known && len(newer) == 0 {
+		source.Logger.Debugf("dependency %s@%s is more recent than all tagged releases", module.Path, module.Version)
+		module.Update = nil
+		module.NextUpdate = nil
+		module.Lag = versionLag(module.Version, "", nil)
+		return
+	}
+	module.NextUpdate = module.Update
+	if len(newer) != 0 && newer[0] != module.Update.Version {
+		module.NextUpdate = a.getNextUpdate(ctx, source, module, newer[0])
+	}
+	module.Lag = versionLag(module.Version, module.Update.Version, newer)
+}
+
+func (a *Analyzer) getNextUpdate(ctx context.Context, source *Source, module *ModulePublic, version string) *ModulePublic {
+	proxyCtx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+	defer cancel()
+	info, err := a.proxy.Info(proxyCtx, module.Path, version)
+	if err == nil {
+		return info
+	}
+	if !errors.Is(err, ErrNoProxy) {
+		source.Logger.Warnf("could not query proxy for %s@%s, inaccurate deprecation date: %s", module.Path, version, err)
+		return nil
+	}
+
+	name := fmt.Sprintf("%s@%s", module.Path, version)
+	depModules, err := a.getModules(ctx, source, source.Dir, name)
+	if err != nil {
+		source.Logger.Warnf("could not analyze dependency %s, inaccurate deprecation date: %s", name, err)
+		return nil
+	}
+	return &(depModules[0])
+}
+
+// getNewerVersions - tagged versions more recent than current one in semver order, false when
+// versions of module are unknown. Pseudo-versions not derived from any tag sort before every
+// release, so byTime keeps only releases published after the commit
+func (a *Analyzer) getNewerVersions(ctx context.Context, source *Source, module *ModulePublic, byTime bool) ([]string, bool) {
+	proxyCtx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+	defer cancel()
+	versions, err := a.proxy.Versions(proxyCtx, module.Path)
+	if err != nil {
+		if !errors.Is(err, ErrNoProxy) {
+			source.Logger.Debugf("could not list versions of %s from proxy: %s", module.Path, err)
+		}
+		versions = module.Versions
+	} else {
+		// unlike go list, proxies also list retracted versions
+		versions = a.withoutRetracted(ctx, source, module.Path, versions)
+	}
+	module.Versions = versions
+	if len(versions) == 0 {
+		return nil, false
+	}
+
+	newer := []string{}
+	for _, cVersion := range versions {
+		if semver.Compare(cVersion, module.Version) <= 0 {
+			continue
+		}
+		// go never upgrades a module having a go.mod to an +incompatible version
+		if !module.Incompatible && semver.Build(cVersion) == "+incompatible" {
+			continue
+		}
+		newer = append(newer, cVersion)
+	}
+
+	if byTime && module.Time != nil {
+		newer = a.releasedAfter(ctx, module, newer, *module.Time)
+	}
+	return newer, true
+}
+
+// withoutRetracted - given versions of module in semver order, minus the ones retracted by go.mod
+// of its latest version as go does
+func (a *Analyzer) withoutRetracted(ctx context.Context, source *Source, path string, versions []string) []string {
+	latest := ""
+	for _, cVersion := range versions {
+		if semver.Build(cVersion) == "+incompatible" {
+			continue
+		}
+		if latest == "" || semver.Prerelease(latest) != "" || semver.Prerelease(cVersion) == "" {
+			latest = cVersion
+		}
+	}
+	if latest == "" {
+		return versions
+	}
+
+	proxyCtx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+	defer cancel()
+	intervals, err := a.proxy.Retractions(proxyCtx, path, latest)
+	if err != nil {
+		source.Logger.Debugf("could not read retractions of %s@%s, keeping all versions: %s", path, latest, err)
+		return versions
+	}
+	if len(intervals) == 0 {
+		return versions
+	}
+	kept := []string{}
+	for _, cVersion := range versions {
+		if !isRetracted(intervals, cVersion) {
+			kept = append(kept, cVersion)
+		}
+	}
+	return kept
+}
+
+// releasedAfter - suffix of given versions published after given time, assuming release times
+// grow with versions
+func (a *Analyzer) releasedAfter(ctx context.Context, module *ModulePublic, versions []string, at time.Time) []string {
+	idx := sort.Search(len(versions), func(i int) bool {
+		proxyCtx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+		defer cancel()
+		info, err := a.proxy.Info(proxyCtx, module.Path, versions[i])
+		if err != nil || info.Time == nil {
+			return true
+		}
+		return info.Time.After(at)
+	})
+	return versions[idx:]
+}
+
+// getNextMajor - latest version of the highest major module path succeeding given module, if any
+func (a *Analyzer) getNextMajor(ctx context.Context, source *Source, module *ModulePublic) *ModulePublic {
+	current, pathOf, ok := majorPaths(module.Path)
+	if !ok {
+		return nil
+	}
+	// +incompatible versions are published on the path of major 1
+	if major, ok := versionMajor(module.Version); ok && major > current {
+		current = major
+	}
+
+	var latest *ModulePublic
+	for major := current + 1; ctx.Err() == nil; major++ {
+		proxyCtx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+		info, err := a.proxy.Latest(proxyCtx, pathOf(major))
+		cancel()
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNoProxy) {
+				source.Logger.Debugf("could not probe major version %s: %s", pathOf(major), err)
+			}
+			break
+		}
+		latest = info
+	}
+	if latest != nil {
+		source.Logger.Debugf("dependency %s has newer major module %s@%s", module.Path, latest.Path, latest.Version)
+	}
+	return latest
+}
+
+// getReleaseCadence - latest release of module, number of releases during last year and average
+// interval between its most recent releases. Releases of last year are counted up to the number
+// needed by staleness thresholds, so that proxy lookups stay bounded
+func (a *Analyzer) getReleaseCadence(ctx context.Context, source *Source, module *ModulePublic) *ReleaseCadence {
+	cadence := &ReleaseCadence{Latest: module.Update}
+	if cadence.Latest == nil && !module.Pseudo {
+		cadence.Latest = &ModulePublic{Path: module.Path, Version: module.Version, Time: module.Time}
+	}
+
+	lookups := max(a.config.CadenceReleases, a.config.Staleness.Merge(source.Staleness).MinYearlyReleases)
+	yearAgo := time.Now().AddDate(-1, 0, 0)
+	times := []time.Time{}
+	for cIdx := len(module.Versions) - 1; cIdx >= 0 && len(times) < lookups; cIdx-- {
+		cVersion := module.Versions[cIdx]
+		if semver.Prerelease(cVersion) != "" {
+			continue
+		}
+		if !module.Incompatible && semver.Build(cVersion) == "+incompatible" {
+			continue
+		}
+		releaseTime, ok := a.getReleaseTime(ctx, module, cadence.Latest, cVersion)
+		if !ok {
+			continue
+		}
+		if cadence.Latest == nil {
+			cadence.Latest = &ModulePublic{Path: module.Path, Version: cVersion}
+		}
+		if cadence.Latest.Version == cVersion && cadence.Latest.Time == nil {
+			latest := *cadence.Latest
+			latest.Time = &releaseTime
+			cadence.Latest = &latest
+		}
+		times = append(times, releaseTime)
+		if releaseTime.After(yearAgo) {
+			cadence.LastYear++
+			continue
+		}
+		// versions are walked newest first, older ones are out of the window as well and
+		// two releases are enough to tell the interval of an inactive module
+		if len(times) >= 2 {
+			break
+		}
+	}
+
+	if len(times) > a.config.CadenceReleases {
+		times = times[:a.config.CadenceReleases]
+	}
+	if len(times) < 2 {
+		return cadence
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	cadence.Releases = len(times)
+	cadence.Interval = times[len(times)-1].Sub(times[0]) / time.Duration(len(times)-1)
+	return cadence
+}
+
+func (a *Analyzer) getReleaseTime(ctx context.Context, module *ModulePublic, latest *ModulePublic, version string) (time.Time, bool) {
+	switch {
+	case latest != nil && latest.Version == version && latest.Time != nil:
+		return *latest.Time, true
+	case module.Version == version && module.Time != nil:
+		return *module.Time, true
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.config.ProxyTimeout)
+	defer cancel()
+	info, err := a.proxy.Info(ctx, module.Path, version)
+	if err != nil || info.Time == nil {
+		return time.Time{}, false
+	}
+	return *info.Time, true
+}
@@ -0,0 +1,532 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestGetNewerVersions(t *testing.T) {
+	const listing = "v1.0.0\nv1.1.0\nv1.2.0\nv1.2.1\nv1.3.0\nv1.4.0-rc.1\nv2.0.0+incompatible\n"
+	tests := []struct {
+		name     string
+		goMod    string
+		versions []string
+		newer    []string
+	}{
+		{
+			name:     "no retraction",
+			goMod:    "module example.com/dep\n",
+			versions: []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0", "v1.4.0-rc.1", "v2.0.0+incompatible"},
+			newer:    []string{"v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0", "v1.4.0-rc.1"},
+		},
+		{
+			name:     "retracted by latest release",
+			goMod:    "module example.com/dep\n\nretract [v1.2.0, v1.2.9] // broken\nretract v1.3.0\n",
+			versions: []string{"v1.0.0", "v1.1.0", "v1.4.0-rc.1", "v2.0.0+incompatible"},
+			newer:    []string{"v1.1.0", "v1.4.0-rc.1"},
+		},
+		{
+			name:     "unreadable go.mod keeps all versions",
+			versions: []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0", "v1.4.0-rc.1", "v2.0.0+incompatible"},
+			newer:    []string{"v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0", "v1.4.0-rc.1"},
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/example.com/dep/@v/list":
+					_, _ = w.Write([]byte(listing))
+				case r.URL.Path == "/example.com/dep/@v/v1.3.0.mod" && cTest.goMod != "":
+					_, _ = w.Write([]byte(cTest.goMod))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			analyzer, err := New(Config{Proxy: ProxyConfig{GoProxy: server.URL, GoNoProxy: "none.invalid"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			module := &ModulePublic{Path: "example.com/dep", Version: "v1.0.0"}
+			newer, known := analyzer.getNewerVersions(context.Background(), &Source{Logger: discard{}}, module, false)
+			if !known {
+				t.Fatalf("expected known versions")
+			}
+			if !reflect.DeepEqual(newer, cTest.newer) {
+				t.Errorf("expected newer versions %v, got %v", cTest.newer, newer)
+			}
+			if !reflect.DeepEqual(module.Versions, cTest.versions) {
+				t.Errorf("expected versions %v, got %v", cTest.versions, module.Versions)
+			}
+		})
+	}
+}
+
+func TestGetReleaseCadence(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		ages     []int // age in days of v1.0.0, v1.1.0... in this order
+		lookups  int
+		lastYear int
+		releases int
+	}{
+		{
+			name:     "inactive module stops at first release out of last year",
+			ages:     []int{900, 800, 700, 600, 500, 400, 20, 10},
+			lookups:  2,
+			lastYear: 2,
+			releases: 3,
+		},
+		{
+			name:     "active module stops at cadence releases",
+			ages:     []int{90, 80, 70, 60, 50, 40, 30, 20, 10},
+			lookups:  4,
+			lastYear: 5,
+			releases: 5,
+		},
+		{
+			name:     "abandoned module",
+			ages:     []int{900, 800, 700},
+			lookups:  1,
+			lastYear: 0,
+			releases: 2,
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			versions := []string{}
+			infos := map[string]string{}
+			for cIdx, cAge := range cTest.ages {
+				version := fmt.Sprintf("v1.%d.0", cIdx)
+				versions = append(versions, version)
+				infos["/example.com/dep/@v/"+version+".info"] = fmt.Sprintf(
+					`{"Version":"%s","Time":"%s"}`, version, now.AddDate(0, 0, -cAge).Format(time.RFC3339),
+				)
+			}
+			lookups := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				info, ok := infos[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				lookups++
+				_, _ = w.Write([]byte(info))
+			}))
+			defer server.Close()
+
+			analyzer, err := New(Config{Proxy: ProxyConfig{GoProxy: server.URL, GoNoProxy: "none.invalid"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			latest := versions[len(versions)-1]
+			latestTime := now.AddDate(0, 0, -cTest.ages[len(versions)-1])
+			module := &ModulePublic{
+				Path:     "example.com/dep",
+				Version:  "v0.1.0",
+				Versions: versions,
+				Update:   &ModulePublic{Path: "example.com/dep", Version: latest, Time: &latestTime},
+			}
+			cadence := analyzer.getReleaseCadence(context.Background(), &Source{Logger: discard{}}, module)
+			if lookups != cTest.lookups {
+				t.Errorf("expected %d lookups, got %d", cTest.lookups, lookups)
+			}
+			if cadence.LastYear != cTest.lastYear || cadence.Releases != cTest.releases {
+				t.Errorf("expected %d releases last year and %d releases, got %+v", cTest.lastYear, cTest.releases, cadence)
+			}
+			if cadence.Latest.Version != latest {
+				t.Errorf("expected latest %s, got %s", latest, cadence.Latest.Version)
+			}
+		})
+	}
+}
+
+func TestGetRepository(t *testing.T) {
+	origin := t.TempDir()
+	repository, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(content string) plumbing.Hash {
+		if err := os.WriteFile(filepath.Join(origin, "go.mod"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("go.mod"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	main := commit("module example.com/main\n")
+	pull := commit("module example.com/pull\n")
+	// pull request head is only reachable from its own reference
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference("refs/pull/1/head", pull),
+		plumbing.NewHashReference(plumbing.Master, main),
+	}
+	for _, cRef := range refs {
+		if err := repository.Storer.SetReference(cRef); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		ref    string
+		cached bool
+		commit plumbing.Hash
+		module string
+	}{
+		{"branch", "master", false, main, "example.com/main"},
+		{"pull request", "refs/pull/1/head", false, pull, "example.com/pull"},
+		{"cached pull request", "refs/pull/1/head", true, pull, "example.com/pull"},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			config := Config{}
+			if cTest.cached {
+				config.Cache.Dir = t.TempDir()
+			}
+			analyzer, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			dir := t.TempDir()
+			source := &Source{URL: origin, Ref: cTest.ref, Logger: discard{}}
+			_, hash, err := analyzer.getRepository(context.Background(), source, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if hash != cTest.commit.String() {
+				t.Errorf("expected commit %s, got %s", cTest.commit, hash)
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(content) != "module "+cTest.module+"\n" {
+				t.Errorf("expected module %s, got go.mod %q", cTest.module, content)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRelativeDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/demo\n\ngo 1.21\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative, err := filepath.Rel(cwd, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// project has no dependency, go list only looks for releases of the main module
+	proxy := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(proxy, "example.com/demo/@v"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proxy, "example.com/demo/@v/list"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	analyzer, err := New(Config{Proxy: ProxyConfig{GoProxy: "file://" + proxy, GoNoProxy: "none.invalid"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	report, err := analyzer.Analyze(context.Background(), Source{Dir: relative})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if report.Module != "example.com/demo" || report.GoMod != "go.mod" {
+		t.Errorf("expected go.mod of example.com/demo, got %s of %s", report.GoMod, report.Module)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	origin := t.TempDir()
+	repository, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// branch and tag named like abbreviated commit hashes
+	for _, cName := range []plumbing.ReferenceName{"refs/heads/20240101", "refs/tags/1234567"} {
+		if err := repository.Storer.SetReference(plumbing.NewHashReference(cName, hash)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ref  string
+		name plumbing.ReferenceName
+		err  string
+	}{
+		{ref: "master", name: plumbing.Master},
+		{ref: "20240101", name: "refs/heads/20240101"},
+		{ref: "1234567", name: "refs/tags/1234567"},
+		{ref: "refs/tags/1234567", name: "refs/tags/1234567"},
+		{ref: "release", err: "no branch nor tag named release"},
+		{ref: hash.String()[:7], err: "abbreviated commit hashes are not supported"},
+	}
+	analyzer, err := New(Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.ref, func(t *testing.T) {
+			ref, err := analyzer.resolveRef(context.Background(), &Source{URL: origin, Ref: cTest.ref})
+			if cTest.err != "" {
+				if err == nil || !strings.Contains(err.Error(), cTest.err) {
+					t.Errorf("expected error containing %q, got %v", cTest.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if ref.Name() != cTest.name || ref.Hash() != hash {
+				t.Errorf("expected %s at %s, got %s", cTest.name, hash, ref)
+			}
+		})
+	}
+}
+
+func TestResolveRefTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	analyzer, err := New(Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = analyzer.resolveRef(ctx, &Source{URL: server.URL + "/repo.git"})
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("listing of hanging remote was not interrupted, took %s", elapsed)
+	}
+}
+
+// writeProxyVersions - publish given versions of module in file proxy dir, released one day apart
+func writeProxyVersions(t *testing.T, proxy string, path string, versions ...string) {
+	t.Helper()
+	dir := filepath.Join(proxy, path, "@v")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for cIdx, cVersion := range versions {
+		info := fmt.Sprintf(`{"Version":"%s","Time":"%s"}`, cVersion, released.AddDate(0, 0, cIdx).Format(time.RFC3339))
+		if err := os.WriteFile(filepath.Join(dir, cVersion+".info"), []byte(info), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, cVersion+".mod"), []byte("module "+path+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "list"), []byte(strings.Join(versions, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyzeIncremental(t *testing.T) {
+	proxy := t.TempDir()
+	writeProxyVersions(t, proxy, "example.com/dep", "v1.0.0", "v1.1.0")
+	writeProxyVersions(t, proxy, "example.com/demo")
+
+	dir := t.TempDir()
+	goMod := "module example.com/demo\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("go.mod"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer, err := New(Config{Proxy: ProxyConfig{GoProxy: "file://" + proxy, GoNoProxy: "none.invalid", GoPrivate: "example.com"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	analyze := func(previous *Report) *Report {
+		t.Helper()
+		report, err := analyzer.Analyze(context.Background(), Source{URL: "https://example.com/demo", Dir: dir, Previous: previous})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return report
+	}
+	// go list may complete go.sum during the first analysis, which then changes the fingerprint
+	analyze(nil)
+	full := analyze(nil)
+	if full.Incremental || len(full.Dependencies) != 1 || full.Dependencies[0].Line != 5 {
+		t.Fatalf("expected full analysis of example.com/dep required at line 5, got %+v", full)
+	}
+	writeProxyVersions(t, proxy, "example.com/dep", "v1.0.0", "v1.1.0", "v1.2.0")
+
+	t.Run("unchanged sources", func(t *testing.T) {
+		// dependencies are taken from previous report, only upstream data is refreshed
+		previous := *full
+		previous.Dependencies = []ModulePublic{full.Dependencies[0]}
+		previous.Dependencies[0].Line = 42
+		report := analyze(&previous)
+		if !report.Incremental {
+			t.Fatalf("expected incremental analysis")
+		}
+		dep := report.Dependencies[0]
+		if dep.Line != 42 {
+			t.Errorf("expected dependency of previous report, got line %d", dep.Line)
+		}
+		if dep.Update == nil || dep.Update.Version != "v1.2.0" || dep.Lag == nil || dep.Lag.Behind != 2 {
+			t.Errorf("expected refreshed update to v1.2.0, 2 versions behind, got %+v and %+v", dep.Update, dep.Lag)
+		}
+	})
+
+	t.Run("failed previous analysis", func(t *testing.T) {
+		previous := *full
+		previous.Success = false
+		if report := analyze(&previous); report.Incremental {
+			t.Errorf("expected full analysis")
+		}
+	})
+
+	t.Run("changed go.mod", func(t *testing.T) {
+		// uncommitted change, commit remains the same
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod+"\nrequire example.com/dep v1.1.0\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		report := analyze(full)
+		if report.Incremental {
+			t.Fatalf("expected full analysis")
+		}
+		if report.Commit != full.Commit {
+			t.Errorf("expected commit %s, got %s", full.Commit, report.Commit)
+		}
+		if dep := report.Dependencies[0]; dep.Version != "v1.1.0" {
+			t.Errorf("expected example.com/dep v1.1.0 of new go.mod, got %s", dep.Version)
+		}
+	})
+}
+
+func TestIsUnchanged(t *testing.T) {
+	previous := Report{
+		SchemaVersion: ReportSchemaVersion, Repository: "https://example.com/demo", Ref: "main", Success: true,
+		Commit: "f8799b994b92af6ce7f83984d510a870ba2c14db", Fingerprint: "0123", Toolchain: "go1.26.0",
+	}
+	tests := []struct {
+		name      string
+		update    func(previous *Report, report *Report)
+		unchanged bool
+	}{
+		{"same sources", func(*Report, *Report) {}, true},
+		{"failed previous analysis", func(previous *Report, _ *Report) { previous.Success = false }, false},
+		{"previous schema", func(previous *Report, _ *Report) { previous.SchemaVersion-- }, false},
+		{"other ref", func(_ *Report, report *Report) { report.Ref = "release" }, false},
+		{"other commit", func(_ *Report, report *Report) { report.Commit = "11b9c14b07b470fdf64c8923cba840754a12c605" }, false},
+		{"unknown commit", func(previous *Report, report *Report) { previous.Commit, report.Commit = "", "" }, false},
+		{"other fingerprint", func(_ *Report, report *Report) { report.Fingerprint = "4567" }, false},
+		{"unknown fingerprint", func(previous *Report, report *Report) { previous.Fingerprint, report.Fingerprint = "", "" }, false},
+		{"other toolchain", func(_ *Report, report *Report) { report.Toolchain = "go1.27.0" }, false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			previous, report := previous, previous
+			cTest.update(&previous, &report)
+			if unchanged := isUnchanged(&previous, &report); unchanged != cTest.unchanged {
+				t.Errorf("expected %t, got %t", cTest.unchanged, unchanged)
+			}
+		})
+	}
+	if isUnchanged(nil, &previous) {
+		t.Errorf("expected missing previous report to be changed")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	if fingerprint(dir) != "" {
+		t.Errorf("expected empty fingerprint without go.mod")
+	}
+	write := func(name string, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/demo\n")
+	seen := map[string]bool{fingerprint(dir): true}
+	for _, cStep := range []struct{ name, content string }{
+		{"go.sum", ""},
+		{"go.sum", "example.com/dep v1.0.0/go.mod h1:abc=\n"},
+		{"go.mod", "module example.com/demo\n\ngo 1.21\n"},
+		{"go.work", "go 1.21\n"},
+	} {
+		write(cStep.name, cStep.content)
+		value := fingerprint(dir)
+		if seen[value] {
+			t.Errorf("expected fingerprint to change with %s %q", cStep.name, cStep.content)
+		}
+		seen[value] = true
+	}
+	write("README.md", "not a module file\n")
+	if value := fingerprint(dir); !seen[value] {
+		t.Errorf("expected fingerprint to ignore other files")
+	}
+}
@@ -0,0 +1,318 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// CacheConfig - local bare mirrors of analyzed repositories, empty directory to clone each
+// project from scratch at every analysis
+type CacheConfig struct {
+	Dir string `yaml:"dir"`
+	// least recently used mirrors are evicted once cache exceeds this size, 0 for no limit
+	MaxSizeMB int64 `yaml:"max_size_mb"`
+}
+
+// Validate - check cache settings and create its directory
+func (c *CacheConfig) Validate() error {
+	if c.Dir == "" {
+		return nil
+	}
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("max_size_mb must be positive")
+	}
+	if err := os.MkdirAll(c.Dir, 0o750); err != nil {
+		return fmt.Errorf("unable to create cache directory '%s': %s", c.Dir, err)
+	}
+	return nil
+}
+
+// mirrorRefSpecs - branches and tags kept in mirrors
+var mirrorRefSpecs = []gitconfig.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// mirrorCache - one bare mirror per repository url, updated by incremental fetches
+type mirrorCache struct {
+	config *CacheConfig
+	logger Logger
+	mutex  sync.Mutex
+	locks  map[string]*sync.Mutex
+}
+
+// newMirrorCache - create mirrorCache from given validated configuration, nil when disabled
+func newMirrorCache(config *CacheConfig, logger Logger) *mirrorCache {
+	if config.Dir == "" {
+		return nil
+	}
+	return &mirrorCache{
+		config: config,
+		logger: logger,
+		locks:  map[string]*sync.Mutex{},
+	}
+}
+
+// mirrorName - matches directory names given by name
+var mirrorName = regexp.MustCompile(`^[A-Za-z0-9._-]+-[0-9a-f]{8}$`)
+
+// name - directory name of mirror of given url, readable, free of collisions and of credentials
+func (c *mirrorCache) name(url string) string {
+	return utils.SafeName(utils.RedactURL(url), url)
+}
+
+// lock - hold mirror of given name until returned function is called
+func (c *mirrorCache) lock(name string) func() {
+	c.mutex.Lock()
+	mutex, ok := c.locks[name]
+	if !ok {
+		mutex = &sync.Mutex{}
+		c.locks[name] = mutex
+	}
+	c.mutex.Unlock()
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// update - open mirror of source, creating it when missing, and fetch changes of remote
+func (c *mirrorCache) update(ctx context.Context, source *Source, path string, ref *plumbing.Reference) (*git.Repository, error) {
+	repository, err := git.PlainOpen(path)
+	if err != nil {
+		if err != git.ErrRepositoryNotExists {
+			source.Logger.Warnf("dropping unreadable mirror: %s", err)
+			if err = os.RemoveAll(path); err != nil {
+				return nil, errors.Wrap(err, "unable to remove mirror")
+			}
+		}
+		source.Logger.Debugf("creating mirror in %s", path)
+		if repository, err = git.PlainInit(path, true); err != nil {
+			return nil, errors.Wrap(err, "unable to create mirror")
+		}
+		_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+			Name:  git.DefaultRemoteName,
+			URLs:  []string{source.URL},
+			Fetch: mirrorRefSpecs,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create mirror")
+		}
+	}
+
+	specs := mirrorRefSpecs
+	// references outside of branches and tags, such as pull requests, are fetched on demand
+	if ref != nil && !ref.Name().IsBranch() && !ref.Name().IsTag() {
+		specs = append(append([]gitconfig.RefSpec{}, specs...), gitconfig.RefSpec(fmt.Sprintf("+%s:%[1]s", ref.Name())))
+	}
+	source.Logger.Debugf("fetching mirror")
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   specs,
+		Auth:       source.Auth,
+		Tags:       git.NoTags,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, errors.Wrap(err, "unable to fetch mirror")
+	}
+	now := time.Now()
+	if err = os.Chtimes(path, now, now); err != nil {
+		source.Logger.Warnf("unable to mark mirror as used: %s", err)
+	}
+	return repository, nil
+}
+
+// checkout - update mirror of source and check given ref out in dir, returns name of checked
+// out reference and hash of checked out commit like getRepository
+func (c *mirrorCache) checkout(ctx context.Context, source *Source, ref *plumbing.Reference, dir string) (string, string, error) {
+	name := c.name(source.URL)
+	defer c.lock(name)()
+
+	repository, err := c.update(ctx, source, filepath.Join(c.config.Dir, name), ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	refName := ""
+	var hash plumbing.Hash
+	switch {
+	case commitHash.MatchString(source.Ref):
+		hash = plumbing.NewHash(source.Ref)
+	case ref != nil:
+		refName, hash = ref.Name().String(), ref.Hash()
+	default:
+		// remote did not advertise its HEAD, same fallback as clone
+		head, err := repository.Reference(plumbing.Master, true)
+		if err != nil {
+			return "", "", errors.Wrap(err, "unable to resolve default branch")
+		}
+		refName, hash = head.Name().String(), head.Hash()
+	}
+	// annotated tags point to a tag object instead of a commit
+	if tag, err := repository.TagObject(hash); err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return "", "", errors.Wrapf(err, "unable to resolve tag %s", tag.Name)
+		}
+		hash = commit.Hash
+	}
+
+	// objects are read from the mirror while index and HEAD of the checkout stay in memory,
+	// several refs of a repository never share any mutable state
+	worktreeStorage := &checkoutStorage{
+		EncodedObjectStorer: repository.Storer,
+		ShallowStorer:       repository.Storer,
+		ConfigStorer:        repository.Storer,
+		ModuleStorer:        repository.Storer,
+		IndexStorer:         &memory.IndexStorage{},
+		ReferenceStorer: memory.ReferenceStorage{
+			plumbing.HEAD: plumbing.NewHashReference(plumbing.HEAD, hash),
+		},
+	}
+	checkout, err := git.Open(worktreeStorage, osfs.New(dir))
+	if err != nil {
+		return "", "", errors.Wrap(err, "unable to create worktree")
+	}
+	worktree, err := checkout.Worktree()
+	if err == nil {
+		err = worktree.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "unable to checkout %s", hash)
+	}
+	return refName, hash.String(), nil
+}
+
+// checkoutStorage - storage of a worktree checked out from a mirror
+type checkoutStorage struct {
+	storer.EncodedObjectStorer
+	storer.ShallowStorer
+	storer.IndexStorer
+	storer.ReferenceStorer
+	gitconfig.ConfigStorer
+	storage.ModuleStorer
+}
+
+// prune - remove mirrors of urls not in given list, then least recently used ones until cache
+// fits its size limit, other entries of cache directory are left untouched and not accounted
+func (c *mirrorCache) prune(urls []string) error {
+	keep := map[string]bool{}
+	for _, cURL := range urls {
+		keep[c.name(cURL)] = true
+	}
+	entries, err := os.ReadDir(c.config.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read cache directory '%s'", c.config.Dir)
+	}
+
+	type mirror struct {
+		name string
+		used time.Time
+		size int64
+	}
+	mirrors := []mirror{}
+	total := int64(0)
+	for _, cEntry := range entries {
+		if !cEntry.IsDir() {
+			continue
+		}
+		// cache directory may be shared, only mirrors are ever removed
+		if !c.isMirror(cEntry.Name()) {
+			c.logger.Debugf("ignoring %s of cache directory, not a mirror", cEntry.Name())
+			continue
+		}
+		if !keep[cEntry.Name()] {
+			c.logger.Infof("evicting mirror %s of unconfigured project", cEntry.Name())
+			if err := c.remove(cEntry.Name()); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := cEntry.Info()
+		if err != nil {
+			return errors.Wrapf(err, "unable to stat mirror %s", cEntry.Name())
+		}
+		size, err := dirSize(filepath.Join(c.config.Dir, cEntry.Name()))
+		if err != nil {
+			return err
+		}
+		mirrors = append(mirrors, mirror{name: cEntry.Name(), used: info.ModTime(), size: size})
+		total += size
+	}
+
+	limit := c.config.MaxSizeMB * 1024 * 1024
+	if limit == 0 || total <= limit {
+		return nil
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].used.Before(mirrors[j].used)
+	})
+	for _, cMirror := range mirrors {
+		if total <= limit {
+			break
+		}
+		c.logger.Infof("evicting mirror %s unused since %s to fit cache size limit", cMirror.name, cMirror.used.Format(time.RFC3339))
+		if err := c.remove(cMirror.name); err != nil {
+			return err
+		}
+		total -= cMirror.size
+	}
+	return nil
+}
+
+// isMirror - tells if given entry of cache directory is a bare repository named by name
+func (c *mirrorCache) isMirror(name string) bool {
+	if !mirrorName.MatchString(name) {
+		return false
+	}
+	repository, err := git.PlainOpen(filepath.Join(c.config.Dir, name))
+	if err != nil {
+		return false
+	}
+	config, err := repository.Config()
+	return err == nil && config.Core.IsBare
+}
+
+func (c *mirrorCache) remove(name string) error {
+	defer c.lock(name)()
+	if err := os.RemoveAll(filepath.Join(c.config.Dir, name)); err != nil {
+		return errors.Wrapf(err, "unable to remove mirror %s", name)
+	}
+	return nil
+}
+
+// dirSize - total size of regular files under given directory
+func dirSize(dir string) (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to compute size of '%s'", dir)
+	}
+	return size, nil
+}
@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+func TestMirrorCachePrune(t *testing.T) {
+	config := &CacheConfig{Dir: t.TempDir(), MaxSizeMB: 1}
+	cache := newMirrorCache(config, discard{})
+	configured := "https://example.com/configured.git"
+	unconfigured := "https://example.com/unconfigured.git"
+
+	// entries of cache directory, true when they must survive pruning
+	entries := map[string]bool{cache.name(configured): true, cache.name(unconfigured): false}
+	for _, cURL := range []string{configured, unconfigured} {
+		if _, err := git.PlainInit(filepath.Join(config.Dir, cache.name(cURL)), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// foreign entries, large enough to exceed size limit if they were accounted
+	large := make([]byte, 2*1024*1024)
+	foreign := []string{"backups", "checkout-0123abcd", "notes-deadbeef"}
+	for _, cName := range foreign {
+		if err := os.MkdirAll(filepath.Join(config.Dir, cName), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(config.Dir, cName, "data"), large, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		entries[cName] = true
+	}
+	if _, err := git.PlainInit(filepath.Join(config.Dir, "checkout-0123abcd"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.prune([]string{configured}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for cName, cKept := range entries {
+		_, err := os.Stat(filepath.Join(config.Dir, cName))
+		if cKept && err != nil {
+			t.Errorf("expected %s to be kept: %s", cName, err)
+		}
+		if !cKept && !os.IsNotExist(err) {
+			t.Errorf("expected %s to be evicted", cName)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// Config - settings of an Analyzer, zero values are replaced by defaults
+type Config struct {
+	Proxy           ProxyConfig
+	Vulnerabilities VulnConfig
+	Cache           CacheConfig
+	Staleness       StalenessConfig
+	Ignore          []IgnoreRule
+	Policies        []PolicyRule
+
+	// number of dependencies of a project analyzed concurrently
+	DependencyWorkers int
+	// number of most recent releases used to compute release cadence of dependencies
+	CadenceReleases int
+	CloneTimeout    time.Duration
+	ListTimeout     time.Duration
+	ProxyTimeout    time.Duration
+
+	// receives messages not related to a given source, discarded when nil
+	Logger Logger
+}
+
+// Validate - check settings and replace zero values by defaults
+func (c *Config) Validate() error {
+	if c.DependencyWorkers <= 0 {
+		c.DependencyWorkers = 8
+	}
+	if c.CadenceReleases <= 0 {
+		c.CadenceReleases = 5
+	}
+	if c.CloneTimeout <= 0 {
+		c.CloneTimeout = 5 * time.Minute
+	}
+	if c.ListTimeout <= 0 {
+		c.ListTimeout = 10 * time.Minute
+	}
+	if c.ProxyTimeout <= 0 {
+		c.ProxyTimeout = 30 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = discard{}
+	}
+	if err := c.Proxy.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy configuration: %s", err)
+	}
+	if err := c.Vulnerabilities.Validate(); err != nil {
+		return fmt.Errorf("invalid vulnerabilities configuration: %s", err)
+	}
+	if err := c.Cache.Validate(); err != nil {
+		return fmt.Errorf("invalid cache configuration: %s", err)
+	}
+	if err := c.Staleness.Validate(); err != nil {
+		return fmt.Errorf("invalid staleness configuration: %s", err)
+	}
+	c.Staleness.SetDefaults()
+	for cIdx := range c.Ignore {
+		if err := c.Ignore[cIdx].Validate(); err != nil {
+			return fmt.Errorf("invalid ignore configuration: %s", err)
+		}
+	}
+	names := map[string]bool{}
+	for cIdx := range c.Policies {
+		if err := c.Policies[cIdx].Validate(); err != nil {
+			return fmt.Errorf("invalid policies configuration: %s", err)
+		}
+		if names[c.Policies[cIdx].Name] {
+			return fmt.Errorf("invalid policies configuration: duplicated policy %s", c.Policies[cIdx].Name)
+		}
+		names[c.Policies[cIdx].Name] = true
+	}
+	return nil
+}
+
+// ValidateSource - check settings of given source, its staleness thresholds being checked once
+// merged with validated ones of c
+func (c *Config) ValidateSource(source *Source) error {
+	if err := source.Validate(); err != nil {
+		return err
+	}
+	thresholds := c.Staleness.Merge(source.Staleness)
+	if err := thresholds.Validate(); err != nil {
+		return fmt.Errorf("invalid staleness configuration of %s: %s", utils.RedactURL(source.URL), err)
+	}
+	return nil
+}
+
+// Source - project to analyze
+type Source struct {
+	// git url of the project, also identifies it in reports
+	URL string
+	// authentication used to clone URL, if any
+	Auth transport.AuthMethod
+	// branch, tag, full reference name such as refs/pull/1/head or full commit hash to analyze,
+	// glob such as v1.* selects highest matching semver tag, default branch is used when empty,
+	// only labels reports when Dir is given
+	Ref string
+	// use given checkout instead of cloning URL
+	Dir string
+	// thresholds overriding analyzer ones, if any
+	Staleness *StalenessConfig
+	// ignore rules evaluated before analyzer ones
+	Ignore []IgnoreRule
+	// last successful report of this source, dependencies are not resolved again when its
+	// commit, go.mod and go.sum are unchanged
+	Previous *Report
+	// receives messages related to this source, analyzer logger is used when nil
+	Logger Logger
+}
+
+// Validate - check source specific settings and compile its ignore rules, rules given by caller
+// are left untouched
+func (s *Source) Validate() error {
+	if s.URL == "" && s.Dir == "" {
+		return fmt.Errorf("source needs an url or a directory")
+	}
+	if isRefPattern(s.Ref) {
+		if _, err := path.Match(s.Ref, ""); err != nil {
+			return fmt.Errorf("invalid ref pattern '%s' of %s: %s", s.Ref, utils.RedactURL(s.URL), err)
+		}
+	}
+	if s.Staleness != nil {
+		if err := s.Staleness.Validate(); err != nil {
+			return fmt.Errorf("invalid staleness configuration of %s: %s", utils.RedactURL(s.URL), err)
+		}
+	}
+	// rules are compiled in a private copy, sources sharing their rules can be analyzed concurrently
+	s.Ignore = append([]IgnoreRule{}, s.Ignore...)
+	for cIdx := range s.Ignore {
+		if err := s.Ignore[cIdx].Validate(); err != nil {
+			return fmt.Errorf("invalid ignore configuration of %s: %s", utils.RedactURL(s.URL), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import "testing"
+
+func TestConfigValidateSource(t *testing.T) {
+	config := Config{Staleness: StalenessConfig{SlowingDays: 200}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		name      string
+		staleness *StalenessConfig
+		err       bool
+	}{
+		{"no override", nil, false},
+		{"override within global thresholds", &StalenessConfig{SlowingDays: 300}, false},
+		{"override above global abandoned days", &StalenessConfig{SlowingDays: 800}, true},
+		{"override of both thresholds", &StalenessConfig{SlowingDays: 800, AbandonedDays: 900}, false},
+		{"override below global slowing days", &StalenessConfig{AbandonedDays: 100}, true},
+		{"negative override", &StalenessConfig{MinYearlyReleases: -1}, true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			source := Source{URL: "https://example.com/repo", Staleness: cTest.staleness}
+			err := config.ValidateSource(&source)
+			if cTest.err && err == nil {
+				t.Errorf("expected error")
+			}
+			if !cTest.err && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSourceValidateRef(t *testing.T) {
+	tests := []struct {
+		ref string
+		err bool
+	}{
+		{"", false},
+		{"main", false},
+		{"v1.2.3", false},
+		{"v1.*", false},
+		{"v1.[", true},
+		{"refs/heads/deadbeef", false},
+		{"refs/pull/1/head", false},
+		{"f8799b994b92af6ce7f83984d510a870ba2c14db", false},
+		{"f8799b9", false},
+		{"20240101", false},
+		{"release-deadbeef", false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.ref, func(t *testing.T) {
+			source := Source{URL: "https://example.com/repo", Ref: cTest.ref}
+			err := source.Validate()
+			if cTest.err && err == nil {
+				t.Errorf("expected error")
+			}
+			if !cTest.err && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSourceValidateConcurrently(t *testing.T) {
+	rules := []IgnoreRule{{Regex: "^example.com/", Versions: "<v2.0.0", Until: "2030-01-01"}}
+	done := make(chan error)
+	for cIdx := 0; cIdx < 4; cIdx++ {
+		go func() {
+			source := Source{URL: "https://example.com/repo", Ignore: rules}
+			done <- source.Validate()
+		}()
+	}
+	for cIdx := 0; cIdx < 4; cIdx++ {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if rules[0].regex != nil || rules[0].constraints != nil || !rules[0].until.IsZero() {
+		t.Errorf("rules of caller were modified")
+	}
+}
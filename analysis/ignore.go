@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// IgnoreRule - mute given dependencies, optionally restricted to a version range and until a date
+type IgnoreRule struct {
+	Path     string `yaml:"path"`     // glob matched against dependency path
+	Regex    string `yaml:"regex"`    // regular expression matched against dependency path
+	Versions string `yaml:"versions"` // space separated constraints such as ">=v1.2.0 <v2.0.0"
+	Until    string `yaml:"until"`    // rule expires after this date, formatted as 2006-01-02
+	Reason   string `yaml:"reason"`
+	// drop all series of matching dependencies instead of labelling them as ignored
+	Suppress bool `yaml:"suppress"`
+
+	regex       *regexp.Regexp
+	constraints []versionConstraint
+	until       time.Time
+}
+
+type versionConstraint struct {
+	operator string
+	version  string
+}
+
+func (c versionConstraint) match(version string) bool {
+	cmp := semver.Compare(version, c.version)
+	switch c.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func parseVersionConstraints(value string) ([]versionConstraint, error) {
+	constraints := []versionConstraint{}
+	for _, cField := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+		constraint := versionConstraint{operator: "="}
+		for _, cOperator := range []string{"<=", ">=", "<", ">", "="} {
+			if strings.HasPrefix(cField, cOperator) {
+				constraint.operator = cOperator
+				cField = strings.TrimPrefix(cField, cOperator)
+				break
+			}
+		}
+		if !strings.HasPrefix(cField, "v") {
+			cField = "v" + cField
+		}
+		if !semver.IsValid(cField) {
+			return nil, fmt.Errorf("invalid version '%s'", cField)
+		}
+		constraint.version = cField
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+// Validate - check and compile rule
+func (r *IgnoreRule) Validate() error {
+	if r.Path == "" && r.Regex == "" {
+		return fmt.Errorf("ignore rule needs a path or a regex")
+	}
+	if r.Path != "" {
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return fmt.Errorf("invalid ignore path '%s': %s", r.Path, err)
+		}
+	}
+	if r.Regex != "" {
+		regex, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid ignore regex '%s': %s", r.Regex, err)
+		}
+		r.regex = regex
+	}
+	constraints, err := parseVersionConstraints(r.Versions)
+	if err != nil {
+		return fmt.Errorf("invalid ignore versions '%s': %s", r.Versions, err)
+	}
+	r.constraints = constraints
+	if r.Until != "" {
+		until, err := time.Parse(time.DateOnly, r.Until)
+		if err != nil {
+			return fmt.Errorf("invalid ignore until date '%s': %s", r.Until, err)
+		}
+		// rule remains active during the whole given day
+		r.until = until.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// Expired - tells if rule expiry date is passed at given time
+func (r *IgnoreRule) Expired(at time.Time) bool {
+	return !r.until.IsZero() && !at.Before(r.until)
+}
+
+// Match - tells if rule applies to given dependency version
+func (r *IgnoreRule) Match(module *ModulePublic) bool {
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, module.Path); !ok {
+			return false
+		}
+	}
+	if r.regex != nil && !r.regex.MatchString(module.Path) {
+		return false
+	}
+	for _, cConstraint := range r.constraints {
+		if !cConstraint.match(module.Version) {
+			return false
+		}
+	}
+	return true
+}
+
+// IgnoreMatch - ignore rule applied to a dependency
+type IgnoreMatch struct {
+	Reason   string     `json:",omitempty"`
+	Until    *time.Time `json:",omitempty"` // end of rule validity, if any
+	Suppress bool       `json:",omitempty"`
+}
+
+// Active - tells if match still applies at given time
+func (m *IgnoreMatch) Active(at time.Time) bool {
+	return m.Until == nil || at.Before(*m.Until)
+}
+
+// applyIgnoreRules - mark dependencies matched by the first rule active at given time, returns
+// expired rules
+func applyIgnoreRules(rules []IgnoreRule, deps []ModulePublic, at time.Time) []IgnoreRule {
+	expired := []IgnoreRule{}
+	for _, cRule := range rules {
+		if cRule.Expired(at) {
+			expired = append(expired, cRule)
+		}
+	}
+	for cIdx := range deps {
+		deps[cIdx].Ignored = nil
+		for _, cRule := range rules {
+			if cRule.Expired(at) || !cRule.Match(&deps[cIdx]) {
+				continue
+			}
+			deps[cIdx].Ignored = &IgnoreMatch{
+				Reason:   cRule.Reason,
+				Suppress: cRule.Suppress,
+			}
+			if !cRule.until.IsZero() {
+				until := cRule.until
+				deps[cIdx].Ignored.Until = &until
+			}
+			break
+		}
+	}
+	return expired
+}
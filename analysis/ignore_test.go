@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseVersionConstraints(t *testing.T) {
+	tests := []struct {
+		value       string
+		constraints []versionConstraint
+		err         bool
+	}{
+		{value: "", constraints: []versionConstraint{}},
+		{value: "v1.2.3", constraints: []versionConstraint{{"=", "v1.2.3"}}},
+		{value: "=1.2.3", constraints: []versionConstraint{{"=", "v1.2.3"}}},
+		{
+			value:       ">=v1.2.0 <v2.0.0",
+			constraints: []versionConstraint{{">=", "v1.2.0"}, {"<", "v2.0.0"}},
+		},
+		{
+			value:       ">1.0,<=1.4.2",
+			constraints: []versionConstraint{{">", "v1.0"}, {"<=", "v1.4.2"}},
+		},
+		{value: ">=latest", err: true},
+		{value: "<v1.2.3.4", err: true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.value, func(t *testing.T) {
+			constraints, err := parseVersionConstraints(cTest.value)
+			if cTest.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", constraints)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(constraints, cTest.constraints) {
+				t.Errorf("expected %v, got %v", cTest.constraints, constraints)
+			}
+		})
+	}
+}
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    IgnoreRule
+		path    string
+		version string
+		match   bool
+	}{
+		{"exact path", IgnoreRule{Path: "github.com/foo/bar"}, "github.com/foo/bar", "v1.0.0", true},
+		{"other path", IgnoreRule{Path: "github.com/foo/bar"}, "github.com/foo/baz", "v1.0.0", false},
+		{"glob", IgnoreRule{Path: "github.com/foo/*"}, "github.com/foo/bar", "v1.0.0", true},
+		{"glob stops at slashes", IgnoreRule{Path: "github.com/foo/*"}, "github.com/foo/bar/v2", "v2.0.0", false},
+		{"regex", IgnoreRule{Regex: `^github\.com/foo/`}, "github.com/foo/bar/v2", "v2.0.0", true},
+		{"regex is unanchored", IgnoreRule{Regex: `foo`}, "github.com/org/foo-client", "v1.0.0", true},
+		{"regex mismatch", IgnoreRule{Regex: `^golang\.org/`}, "github.com/foo/bar", "v1.0.0", false},
+		{"glob and regex", IgnoreRule{Path: "github.com/*/bar", Regex: "foo"}, "github.com/org/bar", "v1.0.0", false},
+		{"within range", IgnoreRule{Path: "github.com/foo/bar", Versions: ">=v1.2.0 <v2.0.0"}, "github.com/foo/bar", "v1.5.0", true},
+		{"below range", IgnoreRule{Path: "github.com/foo/bar", Versions: ">=v1.2.0 <v2.0.0"}, "github.com/foo/bar", "v1.1.9", false},
+		{"range upper bound", IgnoreRule{Path: "github.com/foo/bar", Versions: ">=v1.2.0 <v2.0.0"}, "github.com/foo/bar", "v2.0.0", false},
+		{"exact version", IgnoreRule{Path: "github.com/foo/bar", Versions: "1.2.3"}, "github.com/foo/bar", "v1.2.3", true},
+		{"prerelease before release", IgnoreRule{Path: "github.com/foo/bar", Versions: "<1.2.3"}, "github.com/foo/bar", "v1.2.3-rc.1", true},
+		{"pseudo-version", IgnoreRule{Path: "github.com/foo/bar", Versions: "<=v0.1.0"}, "github.com/foo/bar", "v0.0.0-20200101000000-abcdefabcdef", true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if err := cTest.rule.Validate(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if match := cTest.rule.Match(&ModulePublic{Path: cTest.path, Version: cTest.version}); match != cTest.match {
+				t.Errorf("expected %v, got %v", cTest.match, match)
+			}
+		})
+	}
+}
+
+func TestIgnoreRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule IgnoreRule
+	}{
+		{"no path nor regex", IgnoreRule{Versions: "v1.0.0"}},
+		{"invalid glob", IgnoreRule{Path: "github.com/[foo"}},
+		{"invalid regex", IgnoreRule{Regex: "github.com/(foo"}},
+		{"invalid versions", IgnoreRule{Path: "github.com/foo", Versions: ">=next"}},
+		{"invalid until", IgnoreRule{Path: "github.com/foo", Until: "01/02/2026"}},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if err := cTest.rule.Validate(); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestApplyIgnoreRules(t *testing.T) {
+	rules := []IgnoreRule{
+		{Path: "github.com/foo/expired", Until: "2026-03-01", Reason: "expired"},
+		{Path: "github.com/foo/*", Until: "2026-03-10", Reason: "temporary"},
+		{Regex: "^github.com/", Reason: "forever", Suppress: true},
+	}
+	for cIdx := range rules {
+		if err := rules[cIdx].Validate(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	at := time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	deps := []ModulePublic{
+		{Path: "github.com/foo/expired", Version: "v1.0.0"},
+		{Path: "github.com/foo/bar", Version: "v1.0.0"},
+		{Path: "golang.org/x/mod", Version: "v0.1.0", Ignored: &IgnoreMatch{Reason: "stale"}},
+	}
+	expired := applyIgnoreRules(rules, deps, at)
+
+	if len(expired) != 1 || expired[0].Reason != "expired" {
+		t.Errorf("expected expired rule only, got %v", expired)
+	}
+	// expired rule is skipped, next matching one applies
+	if ignored := deps[0].Ignored; ignored == nil || ignored.Reason != "temporary" || !ignored.Until.Equal(until) {
+		t.Errorf("unexpected ignore match %+v", ignored)
+	}
+	if ignored := deps[1].Ignored; ignored == nil || ignored.Reason != "temporary" || !ignored.Active(at) || ignored.Active(until) {
+		t.Errorf("unexpected ignore match %+v", ignored)
+	}
+	if deps[2].Ignored != nil {
+		t.Errorf("expected previous ignore match to be reset, got %+v", deps[2].Ignored)
+	}
+
+	expired = applyIgnoreRules(rules, deps, until)
+	if len(expired) != 2 {
+		t.Errorf("expected 2 expired rules, got %v", expired)
+	}
+	if ignored := deps[1].Ignored; ignored == nil || ignored.Reason != "forever" || !ignored.Suppress || ignored.Until != nil {
+		t.Errorf("unexpected ignore match %+v", ignored)
+	}
+}
@@ -0,0 +1,17 @@
+package analysis
+
+// Logger - receives progress messages of an analysis, satisfied by *logrus.Entry
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// discard - Logger dropping all messages, used when none is given
+type discard struct{}
+
+func (discard) Debugf(string, ...interface{}) {}
+func (discard) Infof(string, ...interface{})  {}
+func (discard) Warnf(string, ...interface{})  {}
+func (discard) Errorf(string, ...interface{}) {}
@@ -0,0 +1,260 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ModulePublic -
+type ModulePublic struct {
+	Path       string        `json:",omitempty"` // module path
+	Version    string        `json:",omitempty"` // module version
+	Versions   []string      `json:",omitempty"` // available module versions
+	Replace    *ModulePublic `json:",omitempty"` // replaced by this module
+	Time       *time.Time    `json:",omitempty"` // time version was created
+	Update     *ModulePublic `json:",omitempty"` // available update (with -u)
+	NextUpdate *ModulePublic `json:",omitempty"` // available update (with -u)
+	Main       bool          `json:",omitempty"` // is this the main module?
+	Indirect   bool          `json:",omitempty"` // module is only indirectly needed by main module
+	Dir        string        `json:",omitempty"` // directory holding local copy of files, if any
+	GoMod      string        `json:",omitempty"` // path to go.mod file describing module, if any
+	GoVersion  string        `json:",omitempty"` // go version used in module
+	Retracted  []string      `json:",omitempty"` // retraction information, if any (with -retracted or -u)
+	Deprecated string        `json:",omitempty"` // deprecation message, if any (with -u)
+	Error      *ModuleError  `json:",omitempty"` // error loading module
+
+	DeprecatedBy string          `json:",omitempty"` // module suggested by deprecation message, if any
+	Vulns        []Vulnerability `json:",omitempty"` // known advisories affecting this version
+	Lag          *VersionLag     `json:",omitempty"` // distance between current and latest version
+	NextMajor    *ModulePublic   `json:",omitempty"` // latest version of highest newer major module path, if any
+	Pseudo       bool            `json:",omitempty"` // version is a pseudo-version
+	Incompatible bool            `json:",omitempty"` // version is an +incompatible major without go.mod
+	Cadence      *ReleaseCadence `json:",omitempty"` // release activity of the module
+	Staleness    string          `json:",omitempty"` // one of Staleness* constants
+	Ignored      *IgnoreMatch    `json:",omitempty"` // ignore rule matching this dependency, if any
+	Line         int             `json:",omitempty"` // line of require directive in main go.mod, 0 when not required
+}
+
+// ReleaseCadence - release activity of a module
+type ReleaseCadence struct {
+	Latest   *ModulePublic `json:",omitempty"` // latest release and its time
+	Releases int           // number of recent releases used to compute Interval
+	Interval time.Duration // average interval between recent releases, 0 when unknown
+	LastYear int           // number of releases published during the last year, counted up to the needed thresholds
+}
+
+// DaysBehind - number of days since version following the current one was released, 0 when
+// up-to-date, false when release time of next version is unknown
+func (m *ModulePublic) DaysBehind(at time.Time) (float64, bool) {
+	if m.Update == nil {
+		return 0, true
+	}
+	if m.Time != nil && m.NextUpdate != nil && m.NextUpdate.Time != nil {
+		return at.Sub(*m.NextUpdate.Time).Hours() / 24.0, true
+	}
+	return 0, false
+}
+
+// DependencyType - direct or indirect, as required by main module
+func (m *ModulePublic) DependencyType() string {
+	if m.Indirect {
+		return "indirect"
+	}
+	return "direct"
+}
+
+// MajorsBehind - number of major versions between current version and latest one, including
+// newer major module paths
+func (m *ModulePublic) MajorsBehind() int {
+	if m.NextMajor != nil {
+		current, cOk := versionMajor(m.Version)
+		next, nOk := versionMajor(m.NextMajor.Version)
+		if cOk && nOk && next > current {
+			return next - current
+		}
+	}
+	if m.Lag != nil {
+		return m.Lag.Major
+	}
+	return 0
+}
+
+// Kinds of update between current and latest version of a module
+const (
+	UpdateNone       = "none"
+	UpdateMajor      = "major"
+	UpdateMinor      = "minor"
+	UpdatePatch      = "patch"
+	UpdatePrerelease = "prerelease"
+)
+
+// VersionLag - semantic distance between current and latest version of a module
+type VersionLag struct {
+	Kind   string // one of Update* constants
+	Behind int    // number of releases newer than current, up to latest
+	Major  int    // major delta
+	Minor  int    // minor delta, when majors are equal
+	Patch  int    // patch delta, when majors and minors are equal
+}
+
+// ModuleError -
+type ModuleError struct {
+	Err string // error text
+}
+
+// deprecationReplacement - first module path suggested by given deprecation message, if any
+func deprecationReplacement(message string) string {
+	for _, cWord := range strings.Fields(message) {
+		// links to issues or documentation are not module paths
+		if strings.Contains(cWord, "://") {
+			continue
+		}
+		cWord = strings.Trim(cWord, "\"'`()[]<>,;:.")
+		if strings.Contains(cWord, "/") && module.CheckPath(cWord) == nil {
+			return cWord
+		}
+	}
+	return ""
+}
+
+// semverParts - numeric major, minor and patch of given semantic version
+func semverParts(version string) ([3]int, bool) {
+	parts := [3]int{}
+	canonical := semver.Canonical(version)
+	if canonical == "" {
+		return parts, false
+	}
+	canonical = strings.TrimSuffix(canonical, semver.Build(canonical))
+	canonical = strings.TrimSuffix(canonical, semver.Prerelease(canonical))
+	for cIdx, cPart := range strings.SplitN(strings.TrimPrefix(canonical, "v"), ".", 3) {
+		value, err := strconv.Atoi(cPart)
+		if err != nil {
+			return parts, false
+		}
+		parts[cIdx] = value
+	}
+	return parts, true
+}
+
+// versionLag - distance between current and latest versions, counting releases of versions in between
+func versionLag(current string, latest string, versions []string) *VersionLag {
+	lag := &VersionLag{Kind: UpdateNone}
+	if latest == "" || semver.Compare(latest, current) <= 0 {
+		return lag
+	}
+	for _, cVersion := range versions {
+		if semver.Compare(cVersion, current) > 0 && semver.Compare(cVersion, latest) <= 0 {
+			lag.Behind++
+		}
+	}
+
+	cParts, cOk := semverParts(current)
+	lParts, lOk := semverParts(latest)
+	if !cOk || !lOk {
+		return lag
+	}
+	switch {
+	case lParts[0] != cParts[0]:
+		lag.Kind = UpdateMajor
+		lag.Major = lParts[0] - cParts[0]
+	case lParts[1] != cParts[1]:
+		lag.Kind = UpdateMinor
+		lag.Minor = lParts[1] - cParts[1]
+	case lParts[2] != cParts[2]:
+		lag.Kind = UpdatePatch
+		lag.Patch = lParts[2] - cParts[2]
+	default:
+		lag.Kind = UpdatePrerelease
+	}
+	return lag
+}
+
+// versionMajor - major of given semantic version, v0 and +incompatible ones included
+func versionMajor(version string) (int, bool) {
+	major := semver.Major(strings.TrimSuffix(version, "+incompatible"))
+	if major == "" {
+		return 0, false
+	}
+	value, err := strconv.Atoi(major[1:])
+	return value, err == nil
+}
+
+// majorPaths - current major of given module path and a generator of successor major module paths,
+// false for local filesystem paths of replacements
+func majorPaths(path string) (int, func(major int) string, bool) {
+	if modfile.IsDirectoryPath(path) {
+		return 0, nil, false
+	}
+	prefix, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok {
+		return 0, nil, false
+	}
+	pathMajor = strings.TrimSuffix(pathMajor, "-unstable")
+	current := 1
+	if pathMajor != "" {
+		value, err := strconv.Atoi(pathMajor[2:])
+		if err != nil {
+			return 0, nil, false
+		}
+		current = value
+	}
+	separator := "/v"
+	if strings.HasPrefix(path, "gopkg.in/") {
+		separator = ".v"
+	}
+	return current, func(major int) string {
+		return fmt.Sprintf("%s%s%d", prefix, separator, major)
+	}, true
+}
+
+// pseudoVersion - commit time and base release of given pseudo-version, false when version is not one
+func pseudoVersion(version string) (time.Time, string, bool) {
+	if !module.IsPseudoVersion(version) {
+		return time.Time{}, "", false
+	}
+	commitTime, err := module.PseudoVersionTime(version)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	base, err := module.PseudoVersionBase(version)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return commitTime, base, true
+}
+
+// isRetracted - tells if given version is within one of given retracted intervals
+func isRetracted(intervals []modfile.VersionInterval, version string) bool {
+	for _, cInterval := range intervals {
+		if semver.Compare(cInterval.Low, version) <= 0 && semver.Compare(version, cInterval.High) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// requireLines - line of each require directive of given go.mod file, by module path
+func requireLines(goMod string) (map[string]int, error) {
+	content, err := os.ReadFile(goMod)
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.ParseLax(goMod, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	lines := map[string]int{}
+	for _, cRequire := range file.Require {
+		if cRequire.Syntax != nil {
+			lines[cRequire.Mod.Path] = cRequire.Syntax.Start.Line
+		}
+	}
+	return lines, nil
+}
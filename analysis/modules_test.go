@@ -0,0 +1,246 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/mod/modfile"
+)
+
+func TestDeprecationReplacement(t *testing.T) {
+	tests := []struct {
+		message     string
+		replacement string
+	}{
+		{"use github.com/foo/bar/v2 instead", "github.com/foo/bar/v2"},
+		{"moved to `example.com/new/module`.", "example.com/new/module"},
+		{"Deprecated: replaced by (golang.org/x/mod).", "golang.org/x/mod"},
+		{"see https://github.com/foo/bar/issues/12", ""},
+		{"see https://github.com/foo/bar/issues/12, use github.com/foo/baz", "github.com/foo/baz"},
+		{"no longer maintained", ""},
+		{"use v2 and/or v3", ""},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.message, func(t *testing.T) {
+			if replacement := deprecationReplacement(cTest.message); replacement != cTest.replacement {
+				t.Errorf("expected %q, got %q", cTest.replacement, replacement)
+			}
+		})
+	}
+}
+
+func TestIsRetracted(t *testing.T) {
+	intervals := []modfile.VersionInterval{
+		{Low: "v1.2.0", High: "v1.2.9"},
+		{Low: "v1.5.0", High: "v1.5.0"},
+	}
+	tests := []struct {
+		version   string
+		retracted bool
+	}{
+		{"v1.1.9", false},
+		{"v1.2.0", true},
+		{"v1.2.3", true},
+		{"v1.2.9", true},
+		{"v1.2.10", false},
+		{"v1.5.0-rc.1", false},
+		{"v1.5.0", true},
+		{"v1.5.1", false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.version, func(t *testing.T) {
+			if retracted := isRetracted(intervals, cTest.version); retracted != cTest.retracted {
+				t.Errorf("expected %v, got %v", cTest.retracted, retracted)
+			}
+		})
+	}
+}
+
+func TestMajorPaths(t *testing.T) {
+	tests := []struct {
+		path    string
+		current int
+		next    string
+		ok      bool
+	}{
+		{"github.com/foo/bar", 1, "github.com/foo/bar/v2", true},
+		{"github.com/foo/bar/v3", 3, "github.com/foo/bar/v4", true},
+		{"gopkg.in/yaml.v2", 2, "gopkg.in/yaml.v3", true},
+		{"../local/v2", 0, "", false},
+		{"./vendor/bar", 0, "", false},
+		{"/opt/src/bar/v2", 0, "", false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.path, func(t *testing.T) {
+			current, pathOf, ok := majorPaths(cTest.path)
+			if ok != cTest.ok || current != cTest.current {
+				t.Fatalf("expected (%d, %v), got (%d, %v)", cTest.current, cTest.ok, current, ok)
+			}
+			if ok && pathOf(current+1) != cTest.next {
+				t.Errorf("expected next path %s, got %s", cTest.next, pathOf(current+1))
+			}
+		})
+	}
+}
+
+func TestMajorsBehind(t *testing.T) {
+	tests := []struct {
+		name   string
+		module ModulePublic
+		behind int
+	}{
+		{
+			name:   "up-to-date",
+			module: ModulePublic{Path: "github.com/foo/bar", Version: "v1.2.0"},
+		},
+		{
+			name:   "major lag within module path",
+			module: ModulePublic{Path: "github.com/foo/bar", Version: "v2.0.0+incompatible", Lag: &VersionLag{Major: 1}},
+			behind: 1,
+		},
+		{
+			name: "newer major module path",
+			module: ModulePublic{
+				Path: "github.com/foo/bar", Version: "v1.2.0", Lag: &VersionLag{},
+				NextMajor: &ModulePublic{Path: "github.com/foo/bar/v3", Version: "v3.1.0"},
+			},
+			behind: 2,
+		},
+		{
+			name: "v0 counts as its own major",
+			module: ModulePublic{
+				Path: "github.com/foo/bar", Version: "v0.4.0", Lag: &VersionLag{},
+				NextMajor: &ModulePublic{Path: "github.com/foo/bar/v2", Version: "v2.0.0"},
+			},
+			behind: 2,
+		},
+		{
+			name: "incompatible major",
+			module: ModulePublic{
+				Path: "github.com/foo/bar", Version: "v3.0.1+incompatible", Lag: &VersionLag{Major: 1},
+				NextMajor: &ModulePublic{Path: "github.com/foo/bar/v5", Version: "v5.0.0"},
+			},
+			behind: 2,
+		},
+		{
+			name: "pseudo-version",
+			module: ModulePublic{
+				Path: "github.com/foo/bar/v2", Version: "v2.0.0-20200101000000-abcdefabcdef", Lag: &VersionLag{},
+				NextMajor: &ModulePublic{Path: "github.com/foo/bar/v3", Version: "v3.0.0"},
+			},
+			behind: 1,
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if behind := cTest.module.MajorsBehind(); behind != cTest.behind {
+				t.Errorf("expected %d, got %d", cTest.behind, behind)
+			}
+		})
+	}
+}
+
+func TestVersionLag(t *testing.T) {
+	versions := []string{
+		"v1.0.0", "v1.0.1", "v1.1.0", "v1.2.0-rc.1", "v1.2.0", "v2.0.0+incompatible", "v3.0.0+incompatible",
+	}
+	tests := []struct {
+		name    string
+		current string
+		latest  string
+		lag     VersionLag
+	}{
+		{"up-to-date", "v1.2.0", "v1.2.0", VersionLag{Kind: UpdateNone}},
+		{"no latest", "v1.2.0", "", VersionLag{Kind: UpdateNone}},
+		{"latest older than current", "v1.2.0", "v1.1.0", VersionLag{Kind: UpdateNone}},
+		{"patch", "v1.0.0", "v1.0.1", VersionLag{Kind: UpdatePatch, Behind: 1, Patch: 1}},
+		{"minor", "v1.0.0", "v1.2.0", VersionLag{Kind: UpdateMinor, Behind: 4, Minor: 2}},
+		{"prerelease to release", "v1.2.0-rc.1", "v1.2.0", VersionLag{Kind: UpdatePrerelease, Behind: 1}},
+		{"release to prerelease", "v1.1.0", "v1.2.0-rc.1", VersionLag{Kind: UpdateMinor, Behind: 1, Minor: 1}},
+		{"incompatible majors", "v2.0.0+incompatible", "v3.0.0+incompatible", VersionLag{Kind: UpdateMajor, Behind: 1, Major: 1}},
+		{"to incompatible major", "v1.2.0", "v3.0.0+incompatible", VersionLag{Kind: UpdateMajor, Behind: 2, Major: 2}},
+		{
+			"pseudo-version after release",
+			"v1.0.1-0.20200101000000-abcdefabcdef", "v1.1.0",
+			VersionLag{Kind: UpdateMinor, Behind: 2, Minor: 1},
+		},
+		{
+			"pseudo-version of next patch",
+			"v1.0.1-0.20200101000000-abcdefabcdef", "v1.0.1",
+			VersionLag{Kind: UpdatePrerelease, Behind: 1},
+		},
+		{
+			"pseudo-version without tag",
+			"v0.0.0-20200101000000-abcdefabcdef", "v1.0.0",
+			VersionLag{Kind: UpdateMajor, Behind: 1, Major: 1},
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if lag := versionLag(cTest.current, cTest.latest, versions); *lag != cTest.lag {
+				t.Errorf("expected %+v, got %+v", cTest.lag, *lag)
+			}
+		})
+	}
+}
+
+func TestPseudoVersion(t *testing.T) {
+	commitTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		version string
+		time    time.Time
+		base    string
+		ok      bool
+	}{
+		{"v1.2.3", time.Time{}, "", false},
+		{"v1.2.3-rc.1", time.Time{}, "", false},
+		{"v2.0.0+incompatible", time.Time{}, "", false},
+		{"v0.0.0-20200102030405-abcdefabcdef", commitTime, "", true},
+		{"v1.2.4-0.20200102030405-abcdefabcdef", commitTime, "v1.2.3", true},
+		{"v1.2.3-rc.1.0.20200102030405-abcdefabcdef", commitTime, "v1.2.3-rc.1", true},
+		{"v2.0.1-0.20200102030405-abcdefabcdef+incompatible", commitTime, "v2.0.0+incompatible", true},
+		{"v1.2.4-0.20201302030405-abcdefabcdef", time.Time{}, "", false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.version, func(t *testing.T) {
+			commit, base, ok := pseudoVersion(cTest.version)
+			if ok != cTest.ok || !commit.Equal(cTest.time) || base != cTest.base {
+				t.Errorf("expected (%s, %q, %v), got (%s, %q, %v)", cTest.time, cTest.base, cTest.ok, commit, base, ok)
+			}
+		})
+	}
+}
+
+func TestDaysBehind(t *testing.T) {
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	released := at.AddDate(0, 0, -200)
+	nextReleased := at.AddDate(0, 0, -100)
+	tests := []struct {
+		name   string
+		module ModulePublic
+		days   float64
+		known  bool
+	}{
+		{"up-to-date", ModulePublic{Version: "v1.0.0"}, 0, true},
+		{
+			"outdated",
+			ModulePublic{
+				Version: "v1.0.0", Time: &released, Update: &ModulePublic{Version: "v1.2.0"},
+				NextUpdate: &ModulePublic{Version: "v1.1.0", Time: &nextReleased},
+			},
+			100, true,
+		},
+		{
+			"next release time unknown",
+			ModulePublic{Version: "v1.0.0", Time: &released, Update: &ModulePublic{Version: "v1.2.0"}, NextUpdate: &ModulePublic{Version: "v1.1.0"}},
+			0, false,
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if days, known := cTest.module.DaysBehind(at); days != cTest.days || known != cTest.known {
+				t.Errorf("expected %.0f, %t, got %.0f, %t", cTest.days, cTest.known, days, known)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+)
+
+// PolicyRule - requirement every matching dependency must fulfill, unset limits are not checked
+type PolicyRule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	// dependencies the rule applies to
+	Type       string `yaml:"type"`        // direct, indirect or empty for both
+	Path       string `yaml:"path"`        // glob matched against dependency path
+	UpdateKind string `yaml:"update_kind"` // only dependencies whose pending update has this kind
+
+	// requirements
+	MaxDaysBehind     *int     `yaml:"max_days_behind"`
+	MaxVersionsBehind *int     `yaml:"max_versions_behind"`
+	MaxMajorLag       *int     `yaml:"max_major_lag"`
+	ForbidRetracted   bool     `yaml:"forbid_retracted"`
+	ForbidDeprecated  bool     `yaml:"forbid_deprecated"`
+	ForbidVulnerable  bool     `yaml:"forbid_vulnerable"`
+	ForbidStates      []string `yaml:"forbid_states"`
+}
+
+// Validate - check rule settings
+func (r *PolicyRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("policy rule needs a name")
+	}
+	switch r.Type {
+	case "", "direct", "indirect":
+	default:
+		return fmt.Errorf("invalid type '%s' of policy %s", r.Type, r.Name)
+	}
+	if r.Path != "" {
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return fmt.Errorf("invalid path '%s' of policy %s: %s", r.Path, r.Name, err)
+		}
+	}
+	switch r.UpdateKind {
+	case "", UpdateMajor, UpdateMinor, UpdatePatch, UpdatePrerelease:
+	default:
+		return fmt.Errorf("invalid update_kind '%s' of policy %s", r.UpdateKind, r.Name)
+	}
+	for _, cState := range r.ForbidStates {
+		if !slices.Contains(StalenessStates, cState) {
+			return fmt.Errorf("invalid state '%s' of policy %s", cState, r.Name)
+		}
+	}
+	return nil
+}
+
+func (r *PolicyRule) applies(module *ModulePublic) bool {
+	if r.Type != "" && r.Type != module.DependencyType() {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, module.Path); !ok {
+			return false
+		}
+	}
+	if r.UpdateKind != "" && (module.Lag == nil || module.Lag.Kind != r.UpdateKind) {
+		return false
+	}
+	return true
+}
+
+// check - reasons why given dependency breaks the rule
+func (r *PolicyRule) check(module *ModulePublic, at time.Time) []string {
+	reasons := []string{}
+	// lag of dependencies with unknown release times can not be checked
+	if r.MaxDaysBehind != nil {
+		if days, known := module.DaysBehind(at); known && days > float64(*r.MaxDaysBehind) {
+			reasons = append(reasons, fmt.Sprintf("%.0f days behind, max %d", days, *r.MaxDaysBehind))
+		}
+	}
+	if r.MaxVersionsBehind != nil && module.Lag != nil && module.Lag.Behind > *r.MaxVersionsBehind {
+		reasons = append(reasons, fmt.Sprintf("%d versions behind, max %d", module.Lag.Behind, *r.MaxVersionsBehind))
+	}
+	if r.MaxMajorLag != nil {
+		if majors := module.MajorsBehind(); majors > *r.MaxMajorLag {
+			reasons = append(reasons, fmt.Sprintf("%d majors behind, max %d", majors, *r.MaxMajorLag))
+		}
+	}
+	if r.ForbidRetracted && len(module.Retracted) != 0 {
+		reasons = append(reasons, "retracted version")
+	}
+	if r.ForbidDeprecated && module.Deprecated != "" {
+		reasons = append(reasons, "deprecated module")
+	}
+	if r.ForbidVulnerable && len(module.Vulns) != 0 {
+		reasons = append(reasons, fmt.Sprintf("%d known vulnerabilities", len(module.Vulns)))
+	}
+	if slices.Contains(r.ForbidStates, module.Staleness) {
+		reasons = append(reasons, fmt.Sprintf("%s dependency", module.Staleness))
+	}
+	return reasons
+}
+
+// PolicyViolation - dependency breaking a policy rule
+type PolicyViolation struct {
+	Dependency string
+	Version    string
+	Type       string
+	Reasons    []string
+}
+
+// PolicyResult - outcome of a policy rule evaluation against a project
+type PolicyResult struct {
+	Name       string
+	Violations []PolicyViolation `json:",omitempty"`
+}
+
+// evaluatePolicies - check all dependencies not muted by an ignore rule against given rules
+func evaluatePolicies(rules []PolicyRule, deps []ModulePublic, at time.Time) []PolicyResult {
+	results := []PolicyResult{}
+	for _, cRule := range rules {
+		result := PolicyResult{Name: cRule.Name}
+		for cIdx := range deps {
+			dep := &deps[cIdx]
+			if dep.Ignored != nil && dep.Ignored.Active(at) {
+				continue
+			}
+			if !cRule.applies(dep) {
+				continue
+			}
+			if reasons := cRule.check(dep, at); len(reasons) != 0 {
+				result.Violations = append(result.Violations, PolicyViolation{
+					Dependency: dep.Path,
+					Version:    dep.Version,
+					Type:       dep.DependencyType(),
+					Reasons:    reasons,
+				})
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
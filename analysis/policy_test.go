@@ -0,0 +1,123 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyRuleApplies(t *testing.T) {
+	direct := ModulePublic{Path: "github.com/foo/bar", Version: "v1.0.0", Lag: &VersionLag{Kind: UpdateMinor}}
+	indirect := ModulePublic{Path: "golang.org/x/text", Version: "v0.3.0", Indirect: true}
+	tests := []struct {
+		name   string
+		rule   PolicyRule
+		module ModulePublic
+		match  bool
+	}{
+		{"any dependency", PolicyRule{}, indirect, true},
+		{"type matches", PolicyRule{Type: "direct"}, direct, true},
+		{"type differs", PolicyRule{Type: "direct"}, indirect, false},
+		{"path matches", PolicyRule{Path: "github.com/foo/*"}, direct, true},
+		{"path differs", PolicyRule{Path: "github.com/foo/*"}, indirect, false},
+		{"update kind matches", PolicyRule{UpdateKind: UpdateMinor}, direct, true},
+		{"update kind differs", PolicyRule{UpdateKind: UpdateMajor}, direct, false},
+		{"update kind without lag", PolicyRule{UpdateKind: UpdateMinor}, indirect, false},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if match := cTest.rule.applies(&cTest.module); match != cTest.match {
+				t.Errorf("expected %t, got %t", cTest.match, match)
+			}
+		})
+	}
+}
+
+func TestPolicyRuleCheck(t *testing.T) {
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	released := at.AddDate(0, 0, -200)
+	nextReleased := at.AddDate(0, 0, -100)
+	limit := func(value int) *int { return &value }
+
+	behind := ModulePublic{
+		Path: "github.com/foo/behind", Version: "v1.0.0", Time: &released,
+		Update:     &ModulePublic{Version: "v1.2.0"},
+		NextUpdate: &ModulePublic{Version: "v1.1.0", Time: &nextReleased},
+		Lag:        &VersionLag{Kind: UpdateMinor, Behind: 3, Minor: 2},
+		NextMajor:  &ModulePublic{Path: "github.com/foo/behind/v3", Version: "v3.0.0"},
+	}
+	// proxy gave no release time of next version
+	unknown := behind
+	unknown.NextUpdate = &ModulePublic{Version: "v1.1.0"}
+	broken := ModulePublic{
+		Path: "github.com/foo/broken", Version: "v0.1.0",
+		Retracted:  []string{"security issue"},
+		Deprecated: "use github.com/foo/fixed",
+		Vulns:      []Vulnerability{{ID: "GO-2020-0001"}, {ID: "GO-2020-0002"}},
+		Staleness:  StalenessAbandoned,
+	}
+
+	tests := []struct {
+		name    string
+		rule    PolicyRule
+		module  ModulePublic
+		reasons []string
+	}{
+		{"no requirement", PolicyRule{}, behind, []string{}},
+		{"days behind", PolicyRule{MaxDaysBehind: limit(90)}, behind, []string{"100 days behind, max 90"}},
+		{"days behind within limit", PolicyRule{MaxDaysBehind: limit(100)}, behind, []string{}},
+		{"unknown release time", PolicyRule{MaxDaysBehind: limit(0)}, unknown, []string{}},
+		{"versions behind", PolicyRule{MaxVersionsBehind: limit(2)}, behind, []string{"3 versions behind, max 2"}},
+		{"versions behind without lag", PolicyRule{MaxVersionsBehind: limit(0)}, broken, []string{}},
+		{"major lag", PolicyRule{MaxMajorLag: limit(1)}, behind, []string{"2 majors behind, max 1"}},
+		{
+			"forbidden properties",
+			PolicyRule{ForbidRetracted: true, ForbidDeprecated: true, ForbidVulnerable: true, ForbidStates: []string{StalenessAbandoned}},
+			broken,
+			[]string{"retracted version", "deprecated module", "2 known vulnerabilities", "abandoned dependency"},
+		},
+		{
+			"forbidden properties absent",
+			PolicyRule{ForbidRetracted: true, ForbidDeprecated: true, ForbidVulnerable: true, ForbidStates: []string{StalenessSlowing}},
+			behind,
+			[]string{},
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if reasons := cTest.rule.check(&cTest.module, at); !reflect.DeepEqual(reasons, cTest.reasons) {
+				t.Errorf("expected %q, got %q", cTest.reasons, reasons)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	until := at.AddDate(0, 0, 1)
+	expired := at.AddDate(0, 0, -1)
+	deps := []ModulePublic{
+		{Path: "github.com/foo/deprecated", Version: "v1.0.0", Deprecated: "unmaintained"},
+		{Path: "github.com/foo/indirect", Version: "v1.0.0", Deprecated: "unmaintained", Indirect: true},
+		{Path: "github.com/foo/ignored", Version: "v1.0.0", Deprecated: "unmaintained", Ignored: &IgnoreMatch{Until: &until}},
+		{Path: "github.com/foo/expired", Version: "v1.0.0", Deprecated: "unmaintained", Ignored: &IgnoreMatch{Until: &expired}},
+		{Path: "github.com/foo/fine", Version: "v1.0.0"},
+	}
+	rules := []PolicyRule{
+		{Name: "no-deprecated", Type: "direct", ForbidDeprecated: true},
+		{Name: "no-vulnerable", ForbidVulnerable: true},
+	}
+	expected := []PolicyResult{
+		{
+			Name: "no-deprecated",
+			Violations: []PolicyViolation{
+				{Dependency: "github.com/foo/deprecated", Version: "v1.0.0", Type: "direct", Reasons: []string{"deprecated module"}},
+				{Dependency: "github.com/foo/expired", Version: "v1.0.0", Type: "direct", Reasons: []string{"deprecated module"}},
+			},
+		},
+		{Name: "no-vulnerable"},
+	}
+	if results := evaluatePolicies(rules, deps, at); !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+}
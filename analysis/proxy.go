@@ -0,0 +1,324 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	// ErrNoProxy - module must be fetched directly from its origin, not through a proxy
+	ErrNoProxy = errors.New("module is not served by any configured GOPROXY")
+	// ErrNotFound - proxy answered 404 or 410 for given module or version
+	ErrNotFound = errors.New("module or version not found")
+)
+
+const defaultGoProxy = "https://proxy.golang.org,direct"
+
+// ProxyConfig - module proxy settings, empty values default to environment
+type ProxyConfig struct {
+	GoProxy    string `yaml:"goproxy"`
+	GoNoProxy  string `yaml:"gonoproxy"`
+	GoPrivate  string `yaml:"goprivate"`
+	GoInsecure string `yaml:"goinsecure"`
+}
+
+// Validate - check proxy settings, empty values are taken from environment
+func (c *ProxyConfig) Validate() error {
+	if c.GoProxy == "" {
+		c.GoProxy = os.Getenv("GOPROXY")
+	}
+	if c.GoProxy == "" {
+		c.GoProxy = defaultGoProxy
+	}
+	if c.GoPrivate == "" {
+		c.GoPrivate = os.Getenv("GOPRIVATE")
+	}
+	if c.GoNoProxy == "" {
+		c.GoNoProxy = os.Getenv("GONOPROXY")
+	}
+	if c.GoNoProxy == "" {
+		c.GoNoProxy = c.GoPrivate
+	}
+	if c.GoInsecure == "" {
+		c.GoInsecure = os.Getenv("GOINSECURE")
+	}
+	_, err := parseGoProxy(c.GoProxy)
+	return err
+}
+
+// Environ - environment given to go sub-processes so that they honour the same settings
+func (c *ProxyConfig) Environ() []string {
+	env := os.Environ()
+	values := map[string]string{
+		"GOPROXY":    c.GoProxy,
+		"GONOPROXY":  c.GoNoProxy,
+		"GOPRIVATE":  c.GoPrivate,
+		"GOINSECURE": c.GoInsecure,
+	}
+	for key, value := range values {
+		if value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	return env
+}
+
+type proxyEntry struct {
+	url string
+	// fallback to next entry on any error, not only on 404 and 410
+	anyError bool
+}
+
+func parseGoProxy(value string) ([]proxyEntry, error) {
+	entries := []proxyEntry{}
+	for value != "" {
+		entry := proxyEntry{url: value}
+		value = ""
+		if idx := strings.IndexAny(entry.url, ",|"); idx >= 0 {
+			entry.anyError = entry.url[idx] == '|'
+			entry.url, value = entry.url[:idx], entry.url[idx+1:]
+		}
+		entry.url = strings.TrimSpace(entry.url)
+		if entry.url == "" {
+			continue
+		}
+		if entry.url != "direct" && entry.url != "off" {
+			if _, err := url.Parse(entry.url); err != nil {
+				return nil, errors.Wrapf(err, "invalid proxy url '%s'", entry.url)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("GOPROXY list is empty")
+	}
+	return entries, nil
+}
+
+// ProxyClient - minimal client of the GOPROXY protocol
+type ProxyClient struct {
+	config  *ProxyConfig
+	proxies []proxyEntry
+	client  *http.Client
+
+	// version infos and go.mod files never change, they are kept for the whole process lifetime
+	mutex       sync.Mutex
+	infos       map[string]ModulePublic
+	retractions map[string][]modfile.VersionInterval
+}
+
+// NewProxyClient - create ProxyClient from given configuration
+func NewProxyClient(config *ProxyConfig) (*ProxyClient, error) {
+	proxies, err := parseGoProxy(config.GoProxy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid GOPROXY value '%s'", config.GoProxy)
+	}
+	return &ProxyClient{
+		config:      config,
+		proxies:     proxies,
+		client:      &http.Client{},
+		infos:       map[string]ModulePublic{},
+		retractions: map[string][]modfile.VersionInterval{},
+	}, nil
+}
+
+// Versions - list of known tagged versions of given module, in semver order
+func (p *ProxyClient) Versions(ctx context.Context, path string) ([]string, error) {
+	content, err := p.fetch(ctx, path, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, cLine := range strings.Split(string(content), "\n") {
+		cVersion := strings.TrimSpace(cLine)
+		// module caches used as proxy also list pseudo-versions, which are not releases
+		if semver.IsValid(cVersion) && !module.IsPseudoVersion(cVersion) {
+			versions = append(versions, cVersion)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	return versions, nil
+}
+
+// Info - version and creation time of given module version
+func (p *ProxyClient) Info(ctx context.Context, path string, version string) (*ModulePublic, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version '%s'", version)
+	}
+	key := path + "@" + version
+	p.mutex.Lock()
+	cached, ok := p.infos[key]
+	p.mutex.Unlock()
+	if ok {
+		return &cached, nil
+	}
+
+	content, err := p.fetch(ctx, path, fmt.Sprintf("@v/%s.info", escaped))
+	if err != nil {
+		return nil, err
+	}
+	info, err := p.parseInfo(path, content)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	p.infos[key] = *info
+	p.mutex.Unlock()
+	return info, nil
+}
+
+// Latest - latest version of given module as reported by the proxy, highest listed
+// release when the proxy does not serve @latest
+func (p *ProxyClient) Latest(ctx context.Context, path string) (*ModulePublic, error) {
+	content, err := p.fetch(ctx, path, "@latest")
+	if err == nil {
+		return p.parseInfo(path, content)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	versions, lErr := p.Versions(ctx, path)
+	if lErr != nil || len(versions) == 0 {
+		return nil, err
+	}
+	latest := versions[len(versions)-1]
+	for cIdx := len(versions) - 1; cIdx >= 0; cIdx-- {
+		if semver.Prerelease(versions[cIdx]) == "" {
+			latest = versions[cIdx]
+			break
+		}
+	}
+	return p.Info(ctx, path, latest)
+}
+
+// GoMod - content of go.mod file of given module version
+func (p *ProxyClient) GoMod(ctx context.Context, path string, version string) ([]byte, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version '%s'", version)
+	}
+	return p.fetch(ctx, path, fmt.Sprintf("@v/%s.mod", escaped))
+}
+
+// Retractions - version intervals retracted by go.mod file of given module version
+func (p *ProxyClient) Retractions(ctx context.Context, path string, version string) ([]modfile.VersionInterval, error) {
+	key := path + "@" + version
+	p.mutex.Lock()
+	cached, ok := p.retractions[key]
+	p.mutex.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	content, err := p.GoMod(ctx, path, version)
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.ParseLax(key+"/go.mod", content, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse go.mod of %s", key)
+	}
+	intervals := []modfile.VersionInterval{}
+	for _, cRetract := range file.Retract {
+		intervals = append(intervals, cRetract.VersionInterval)
+	}
+	p.mutex.Lock()
+	p.retractions[key] = intervals
+	p.mutex.Unlock()
+	return intervals, nil
+}
+
+func (p *ProxyClient) parseInfo(path string, content []byte) (*ModulePublic, error) {
+	info := ModulePublic{}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse proxy info for %s", path)
+	}
+	info.Path = path
+	return &info, nil
+}
+
+func (p *ProxyClient) fetch(ctx context.Context, path string, suffix string) ([]byte, error) {
+	if module.MatchPrefixPatterns(p.config.GoNoProxy, path) {
+		return nil, ErrNoProxy
+	}
+	escaped, err := module.EscapePath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid module path '%s'", path)
+	}
+
+	lastErr := ErrNoProxy
+	for _, cProxy := range p.proxies {
+		switch cProxy.url {
+		case "direct":
+			return nil, ErrNoProxy
+		case "off":
+			return nil, errors.Errorf("module lookup disabled by GOPROXY=off")
+		}
+		content, err := p.get(ctx, cProxy.url, escaped, suffix)
+		if err == nil {
+			return content, nil
+		}
+		lastErr = err
+		if !cProxy.anyError && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+func (p *ProxyClient) get(ctx context.Context, base string, escaped string, suffix string) ([]byte, error) {
+	target, err := url.Parse(base)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid proxy url '%s'", base)
+	}
+
+	if target.Scheme == "file" {
+		content, err := os.ReadFile(filepath.Join(filepath.FromSlash(target.Path), escaped, suffix))
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(ErrNotFound, "%s/%s/%s", base, escaped, suffix)
+		}
+		return content, err
+	}
+
+	target = target.JoinPath(escaped, suffix)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build proxy request")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to query proxy")
+	}
+	defer utils.CloseAndLogError(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, errors.Wrapf(ErrNotFound, "%s", target.Redacted())
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("unexpected status %d from %s", resp.StatusCode, target.Redacted())
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read response of %s", target.Redacted())
+	}
+	return content, nil
+}
@@ -0,0 +1,272 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		entries []proxyEntry
+		err     bool
+	}{
+		{
+			name:    "single",
+			value:   "https://proxy.golang.org",
+			entries: []proxyEntry{{url: "https://proxy.golang.org"}},
+		},
+		{
+			name:  "comma fallback",
+			value: "https://a.example.com,https://b.example.com,direct",
+			entries: []proxyEntry{
+				{url: "https://a.example.com"},
+				{url: "https://b.example.com"},
+				{url: "direct"},
+			},
+		},
+		{
+			name:  "pipe fallback",
+			value: "https://a.example.com|https://b.example.com,off",
+			entries: []proxyEntry{
+				{url: "https://a.example.com", anyError: true},
+				{url: "https://b.example.com"},
+				{url: "off"},
+			},
+		},
+		{
+			name:    "blank entries",
+			value:   " https://a.example.com ,,",
+			entries: []proxyEntry{{url: "https://a.example.com"}},
+		},
+		{
+			name:  "empty",
+			value: ",",
+			err:   true,
+		},
+		{
+			name:  "invalid url",
+			value: "https://a.example.com/%zz",
+			err:   true,
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			entries, err := parseGoProxy(cTest.value)
+			if cTest.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(entries, cTest.entries) {
+				t.Errorf("expected %v, got %v", cTest.entries, entries)
+			}
+		})
+	}
+}
+
+// newTestProxy - proxy server answering given status to every request, counting them
+func newTestProxy(t *testing.T, status int, body string, hits *int) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestProxyClientFetch(t *testing.T) {
+	const listing = "v1.0.0\nv1.1.0\n"
+	tests := []struct {
+		name    string
+		goproxy func(ok, missing, broken string) string
+		noproxy string
+		// expected number of hits of each server, in ok, missing and broken order
+		hits     [3]int
+		content  string
+		notFound bool
+		noProxy  bool
+		err      bool
+	}{
+		{
+			name:    "first proxy answers",
+			goproxy: func(ok, _, _ string) string { return ok + "," + ok },
+			hits:    [3]int{1, 0, 0},
+			content: listing,
+		},
+		{
+			name:    "comma falls back on not found",
+			goproxy: func(ok, missing, _ string) string { return missing + "," + ok },
+			hits:    [3]int{1, 1, 0},
+			content: listing,
+		},
+		{
+			name:    "comma stops on other errors",
+			goproxy: func(ok, _, broken string) string { return broken + "," + ok },
+			hits:    [3]int{0, 0, 1},
+			err:     true,
+		},
+		{
+			name:    "pipe falls back on any error",
+			goproxy: func(ok, _, broken string) string { return broken + "|" + ok },
+			hits:    [3]int{1, 0, 1},
+			content: listing,
+		},
+		{
+			name:     "last not found is reported",
+			goproxy:  func(_, missing, _ string) string { return missing },
+			hits:     [3]int{0, 1, 0},
+			notFound: true,
+		},
+		{
+			name:    "direct stops lookup",
+			goproxy: func(ok, missing, _ string) string { return missing + ",direct," + ok },
+			hits:    [3]int{0, 1, 0},
+			noProxy: true,
+		},
+		{
+			name:    "off disables lookup",
+			goproxy: func(ok, _, _ string) string { return "off," + ok },
+			hits:    [3]int{0, 0, 0},
+			err:     true,
+		},
+		{
+			name:    "gonoproxy matches module prefix",
+			goproxy: func(ok, _, _ string) string { return ok },
+			noproxy: "example.com/private,github.com/org",
+			hits:    [3]int{0, 0, 0},
+			noProxy: true,
+		},
+		{
+			name:    "gonoproxy matches glob",
+			goproxy: func(ok, _, _ string) string { return ok },
+			noproxy: "*.com/org",
+			hits:    [3]int{0, 0, 0},
+			noProxy: true,
+		},
+		{
+			name:    "gonoproxy does not match other modules",
+			goproxy: func(ok, _, _ string) string { return ok },
+			noproxy: "github.com/other,github.com/or",
+			hits:    [3]int{1, 0, 0},
+			content: listing,
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			hits := [3]int{}
+			ok := newTestProxy(t, http.StatusOK, listing, &hits[0])
+			missing := newTestProxy(t, http.StatusGone, "", &hits[1])
+			broken := newTestProxy(t, http.StatusInternalServerError, "", &hits[2])
+
+			client, err := NewProxyClient(&ProxyConfig{
+				GoProxy:   cTest.goproxy(ok, missing, broken),
+				GoNoProxy: cTest.noproxy,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			content, err := client.fetch(context.Background(), "github.com/org/repo", "@v/list")
+			switch {
+			case cTest.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("expected not found error, got %v", err)
+				}
+			case cTest.noProxy:
+				if !errors.Is(err, ErrNoProxy) {
+					t.Errorf("expected no proxy error, got %v", err)
+				}
+			case cTest.err:
+				if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoProxy) {
+					t.Errorf("expected proxy error, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case string(content) != cTest.content:
+				t.Errorf("expected content %q, got %q", cTest.content, content)
+			}
+			if hits != cTest.hits {
+				t.Errorf("expected hits %v, got %v", cTest.hits, hits)
+			}
+		})
+	}
+}
+
+func TestProxyClientRequests(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/github.com/!org/repo/@v/list":
+			_, _ = w.Write([]byte("v1.10.0\nv1.2.0\nnot-a-version\nv1.9.0-rc1\nv1.10.1-0.20200102030405-abcdefabcdef\n"))
+		case "/github.com/!org/repo/@v/v1.2.0.info":
+			_, _ = w.Write([]byte(`{"Version":"v1.2.0","Time":"2020-01-02T03:04:05Z"}`))
+		case "/github.com/!org/repo/@v/v1.2.0.mod":
+			_, _ = w.Write([]byte("module github.com/Org/repo\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewProxyClient(&ProxyConfig{GoProxy: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := context.Background()
+
+	versions, err := client.Versions(ctx, "github.com/Org/repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"v1.2.0", "v1.9.0-rc1", "v1.10.0"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, versions)
+	}
+
+	for range 2 {
+		info, err := client.Info(ctx, "github.com/Org/repo", "v1.2.0")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if info.Path != "github.com/Org/repo" || info.Version != "v1.2.0" || info.Time == nil {
+			t.Errorf("unexpected info %+v", info)
+		}
+	}
+
+	goMod, err := client.GoMod(ctx, "github.com/Org/repo", "v1.2.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(goMod) != "module github.com/Org/repo\n" {
+		t.Errorf("unexpected go.mod %q", goMod)
+	}
+
+	// @latest is not served, highest release of the listing is used instead
+	latest, err := client.Latest(ctx, "github.com/Org/repo")
+	if err == nil {
+		t.Errorf("expected error for unserved info of v1.10.0, got %+v", latest)
+	}
+
+	expected := []string{
+		"/github.com/!org/repo/@v/list",
+		// second info is served from cache
+		"/github.com/!org/repo/@v/v1.2.0.info",
+		"/github.com/!org/repo/@v/v1.2.0.mod",
+		"/github.com/!org/repo/@latest",
+		"/github.com/!org/repo/@v/list",
+		"/github.com/!org/repo/@v/v1.10.0.info",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected requests %v, got %v", expected, paths)
+	}
+}
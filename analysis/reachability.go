@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"context"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// symbolSet - reachable symbols indexed by package path, methods are named "Type.Method"
+type symbolSet map[string]map[string]bool
+
+// reaches - tells if given package, or one of its symbols when listed, is reachable
+func (s symbolSet) reaches(imp VulnImport) bool {
+	symbols, ok := s[imp.Path]
+	if !ok {
+		return false
+	}
+	if len(imp.Symbols) == 0 {
+		return true
+	}
+	for _, cSymbol := range imp.Symbols {
+		if symbols[cSymbol] {
+			return true
+		}
+	}
+	return false
+}
+
+// reachesModule - tells if any package of given module is reachable
+func (s symbolSet) reachesModule(path string) bool {
+	for cPackage := range s {
+		if cPackage == path || strings.HasPrefix(cPackage, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getReachableSymbols - functions reachable from packages of the main module checked out in dir
+func (a *Analyzer) getReachableSymbols(ctx context.Context, source *Source, dir string) (symbolSet, error) {
+	source.Logger.Debugf("building call graph")
+	ctx, cancel := context.WithTimeout(ctx, a.config.ListTimeout)
+	defer cancel()
+
+	pkgs, err := packages.Load(&packages.Config{
+		Context: ctx,
+		Mode:    packages.LoadAllSyntax,
+		Dir:     dir,
+		Env:     a.config.Proxy.Environ(),
+	}, "./...")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load packages")
+	}
+	failures := 0
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, cErr := range pkg.Errors {
+			source.Logger.Warnf("unable to load package %s: %s", pkg.PkgPath, cErr)
+			failures++
+		}
+	})
+	if failures > 0 {
+		return nil, errors.Errorf("unable to load packages without errors, %d found", failures)
+	}
+
+	prog, _ := ssautil.AllPackages(pkgs, ssa.InstantiateGenerics)
+	prog.Build()
+	graph := cha.CallGraph(prog)
+
+	roots := map[string]bool{}
+	for _, cPkg := range pkgs {
+		roots[cPkg.PkgPath] = true
+	}
+	queue := []*ssa.Function{}
+	for cFunc := range ssautil.AllFunctions(prog) {
+		if pkg := cFunc.Pkg; pkg != nil && roots[pkg.Pkg.Path()] {
+			queue = append(queue, cFunc)
+		}
+	}
+
+	visited := map[*ssa.Function]bool{}
+	symbols := symbolSet{}
+	for len(queue) != 0 {
+		cFunc := queue[0]
+		queue = queue[1:]
+		if visited[cFunc] {
+			continue
+		}
+		visited[cFunc] = true
+		if pkgPath, name, ok := functionSymbol(cFunc); ok {
+			if symbols[pkgPath] == nil {
+				symbols[pkgPath] = map[string]bool{}
+			}
+			symbols[pkgPath][name] = true
+		}
+		node := graph.Nodes[cFunc]
+		if node == nil {
+			continue
+		}
+		for _, cEdge := range node.Out {
+			queue = append(queue, cEdge.Callee.Func)
+		}
+	}
+	return symbols, nil
+}
+
+// functionSymbol - package path and symbol name of given function, as used by vulnerability advisories
+func functionSymbol(fn *ssa.Function) (string, string, bool) {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if origin := fn.Origin(); origin != nil {
+		fn = origin
+	}
+	if fn.Pkg == nil {
+		return "", "", false
+	}
+	name := fn.Name()
+	if recv := fn.Signature.Recv(); recv != nil {
+		recvType := recv.Type()
+		if ptr, ok := recvType.(*types.Pointer); ok {
+			recvType = ptr.Elem()
+		}
+		if named, ok := recvType.(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	return fn.Pkg.Pkg.Path(), name, true
+}
+
+func (a *Analyzer) analyzeReachability(ctx context.Context, source *Source, deps []ModulePublic) {
+	affected := false
+	for _, cDep := range deps {
+		affected = affected || len(cDep.Vulns) != 0
+	}
+	if !affected {
+		return
+	}
+
+	symbols, err := a.getReachableSymbols(ctx, source, source.Dir)
+	if err != nil {
+		source.Logger.Warnf("could not compute reachability of vulnerable symbols: %s", err)
+		return
+	}
+	for cIdx := range deps {
+		for cVuln := range deps[cIdx].Vulns {
+			vuln := &deps[cIdx].Vulns[cVuln]
+			reachable := false
+			if len(vuln.Imports) == 0 {
+				reachable = symbols.reachesModule(deps[cIdx].Path)
+			}
+			for _, cImport := range vuln.Imports {
+				reachable = reachable || symbols.reaches(cImport)
+			}
+			vuln.Reachable = ReachableNo
+			if reachable {
+				vuln.Reachable = ReachableYes
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+)
+
+// ReportSchemaVersion - version of Report json layout, incremented on any incompatible change.
+// Fields may be added without changing the version, consumers must ignore unknown fields.
+const ReportSchemaVersion = 1
+
+// Report - machine readable result of a project analysis
+type Report struct {
+	SchemaVersion int       // always ReportSchemaVersion
+	Repository    string    // git url of analyzed project, credentials hidden
+	Time          time.Time // start time of the analysis
+	Success       bool      // analysis completed, otherwise Errors tells why
+	Errors        []string  `json:",omitempty"` // project and dependency level errors
+
+	// branch, tag, commit or tag pattern given as source ref, empty for default branch
+	Ref string `json:",omitempty"`
+	// name of checked out reference, such as refs/heads/main or refs/tags/v1.2.3, if any
+	Reference string `json:",omitempty"`
+
+	Commit    string `json:",omitempty"` // analyzed git commit, if known
+	Module    string `json:",omitempty"` // path of main module
+	GoMod     string `json:",omitempty"` // path of go.mod file of main module, relative to repository root
+	GoVersion string `json:",omitempty"` // go directive of main module
+	Toolchain string `json:",omitempty"` // go toolchain version that ran the analysis
+	// digest of go.mod, go.sum and go.work files of analyzed directory
+	Fingerprint string `json:",omitempty"`
+	// dependencies of previous report were reused, only their upstream versions were refreshed
+	Incremental bool `json:",omitempty"`
+
+	// dependencies of main module, replaced ones are given by their replacement, with all
+	// derived fields (update, lag, vulnerabilities, cadence, staleness, ignore match...)
+	Dependencies []ModulePublic
+	// replaced dependencies, as required by main module, Replace field holding the replacement
+	Replaces []ModulePublic
+	// number of ignore rules past their expiry date
+	ExpiredIgnoreRules int `json:",omitempty"`
+	// evaluation of configured policy rules
+	Policies []PolicyResult `json:",omitempty"`
+}
+
+// newReport - successful report of given source with no dependency
+func newReport(repository string, at time.Time) *Report {
+	return &Report{
+		SchemaVersion: ReportSchemaVersion,
+		Repository:    utils.RedactURL(repository),
+		Time:          at,
+		Success:       true,
+		Dependencies:  []ModulePublic{},
+		Replaces:      []ModulePublic{},
+	}
+}
+
+// NewFailedReport - report of an analysis that failed because of given error
+func NewFailedReport(repository string, at time.Time, err error) *Report {
+	report := newReport(repository, at)
+	report.Success = false
+	report.Errors = []string{err.Error()}
+	return report
+}
+
+// fail - mark report as failed because of given error, partial results are dropped
+func (r *Report) fail(err error) *Report {
+	report := NewFailedReport(r.Repository, r.Time, err)
+	report.Ref = r.Ref
+	return report
+}
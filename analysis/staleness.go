@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+)
+
+// Release activity states of a dependency
+const (
+	StalenessUnknown   = "unknown"
+	StalenessActive    = "active"
+	StalenessSlowing   = "slowing"
+	StalenessAbandoned = "abandoned"
+)
+
+// StalenessStates - all release activity states, in increasing order of severity
+var StalenessStates = []string{StalenessUnknown, StalenessActive, StalenessSlowing, StalenessAbandoned}
+
+// StalenessConfig - thresholds classifying dependencies as active, slowing or abandoned
+type StalenessConfig struct {
+	// days since latest release after which a dependency is slowing
+	SlowingDays int `yaml:"slowing_days"`
+	// days since latest release after which a dependency is abandoned
+	AbandonedDays int `yaml:"abandoned_days"`
+	// dependency is slowing when fewer releases were published during the last year
+	MinYearlyReleases int `yaml:"min_yearly_releases"`
+}
+
+// Validate - check staleness thresholds
+func (c *StalenessConfig) Validate() error {
+	if c.SlowingDays < 0 || c.AbandonedDays < 0 || c.MinYearlyReleases < 0 {
+		return fmt.Errorf("thresholds must be positive")
+	}
+	if c.SlowingDays != 0 && c.AbandonedDays != 0 && c.SlowingDays > c.AbandonedDays {
+		return fmt.Errorf("slowing_days must be lower than abandoned_days")
+	}
+	return nil
+}
+
+// SetDefaults - replace zero thresholds by defaults
+func (c *StalenessConfig) SetDefaults() {
+	if c.SlowingDays == 0 {
+		c.SlowingDays = 365
+	}
+	if c.AbandonedDays == 0 {
+		c.AbandonedDays = 730
+	}
+	if c.MinYearlyReleases == 0 {
+		c.MinYearlyReleases = 2
+	}
+}
+
+// Merge - thresholds of c overridden by non-zero values of given configuration
+func (c StalenessConfig) Merge(override *StalenessConfig) StalenessConfig {
+	if override == nil {
+		return c
+	}
+	if override.SlowingDays != 0 {
+		c.SlowingDays = override.SlowingDays
+	}
+	if override.AbandonedDays != 0 {
+		c.AbandonedDays = override.AbandonedDays
+	}
+	if override.MinYearlyReleases != 0 {
+		c.MinYearlyReleases = override.MinYearlyReleases
+	}
+	return c
+}
+
+// Classify - release activity state of given analyzed dependency
+func (c *StalenessConfig) Classify(module *ModulePublic) string {
+	cadence := module.Cadence
+	if cadence == nil || cadence.Latest == nil || cadence.Latest.Time == nil {
+		return StalenessUnknown
+	}
+	age := time.Since(*cadence.Latest.Time).Hours() / 24.0
+	switch {
+	case age >= float64(c.AbandonedDays):
+		return StalenessAbandoned
+	case age >= float64(c.SlowingDays), cadence.LastYear < c.MinYearlyReleases:
+		return StalenessSlowing
+	}
+	return StalenessActive
+}
@@ -0,0 +1,341 @@
+package analysis
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// VulnConfig - local copy of the Go vulnerability database, empty to disable matching
+type VulnConfig struct {
+	Database string `yaml:"database"`
+	// build call graph of projects to tell if vulnerable symbols are actually used
+	Reachability bool `yaml:"reachability"`
+}
+
+// Validate - check vulnerability database settings
+func (c *VulnConfig) Validate() error {
+	if c.Database == "" {
+		return nil
+	}
+	if _, err := os.Stat(c.Database); err != nil {
+		return fmt.Errorf("vulnerability database '%s' not found: %s", c.Database, err)
+	}
+	return nil
+}
+
+// Vulnerability - advisory affecting a given module version
+type Vulnerability struct {
+	ID       string
+	Aliases  []string `json:",omitempty"`
+	Summary  string   `json:",omitempty"`
+	Severity string   `json:",omitempty"`
+	Fixed    string   `json:",omitempty"` // first version fixing the advisory, if any
+
+	Imports   []VulnImport `json:",omitempty"` // vulnerable packages and symbols
+	Reachable string       `json:",omitempty"` // ReachableYes or ReachableNo, empty when unknown
+}
+
+// VulnImport - package affected by an advisory, all its symbols when none is listed
+type VulnImport struct {
+	Path    string
+	Symbols []string `json:",omitempty"`
+}
+
+// Reachability of vulnerable symbols from the analyzed project
+const (
+	ReachableYes = "true"
+	ReachableNo  = "false"
+)
+
+// osvEntry - subset of the OSV schema used by vuln.go.dev
+type osvEntry struct {
+	ID        string     `json:"id"`
+	Aliases   []string   `json:"aliases"`
+	Summary   string     `json:"summary"`
+	Withdrawn *time.Time `json:"withdrawn"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// osvEcosystem - ecosystem of Go modules in OSV entries
+const osvEcosystem = "Go"
+
+type osvAffected struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Ranges            []osvRange `json:"ranges"`
+	EcosystemSpecific struct {
+		Imports []struct {
+			Path    string   `json:"path"`
+			Symbols []string `json:"symbols"`
+		} `json:"imports"`
+	} `json:"ecosystem_specific"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+func (e *osvEntry) severity() string {
+	if e.DatabaseSpecific.Severity != "" {
+		return strings.ToUpper(e.DatabaseSpecific.Severity)
+	}
+	for _, cSeverity := range e.Severity {
+		if cSeverity.Score != "" {
+			return cSeverity.Score
+		}
+	}
+	return "unknown"
+}
+
+// VulnDB - in-memory index of a vuln.go.dev mirror, from a directory or a zip archive
+type VulnDB struct {
+	path     string
+	logger   Logger
+	mutex    sync.RWMutex
+	modTime  time.Time
+	byModule map[string][]*osvEntry
+}
+
+// NewVulnDB - create VulnDB from given configuration, nil when matching is disabled
+func NewVulnDB(config *VulnConfig, logger Logger) *VulnDB {
+	if config.Database == "" {
+		return nil
+	}
+	db := &VulnDB{path: config.Database, logger: logger}
+	if err := db.Refresh(); err != nil {
+		logger.Errorf("unable to load vulnerability database: %s", err)
+	}
+	return db
+}
+
+// stamp - modification time of the database, index/db.json is rewritten on each update of a mirror
+func (d *VulnDB) stamp() (time.Time, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if info.IsDir() {
+		if index, err := os.Stat(filepath.Join(d.path, "index", "db.json")); err == nil {
+			return index.ModTime(), nil
+		}
+	}
+	return info.ModTime(), nil
+}
+
+// Refresh - reload database if it changed on disk since last load
+func (d *VulnDB) Refresh() error {
+	modTime, err := d.stamp()
+	if err != nil {
+		return errors.Wrapf(err, "unable to access vulnerability database '%s'", d.path)
+	}
+	d.mutex.RLock()
+	upToDate := d.byModule != nil && modTime.Equal(d.modTime)
+	d.mutex.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	entries, err := d.load()
+	if err != nil {
+		return err
+	}
+	byModule := map[string][]*osvEntry{}
+	for _, cEntry := range entries {
+		if cEntry.Withdrawn != nil {
+			continue
+		}
+		for _, cAffected := range cEntry.Affected {
+			name := cAffected.Package.Name
+			if len(byModule[name]) == 0 || byModule[name][len(byModule[name])-1] != cEntry {
+				byModule[name] = append(byModule[name], cEntry)
+			}
+		}
+	}
+	d.logger.Infof("loaded %d advisories from vulnerability database %s", len(entries), d.path)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.modTime = modTime
+	d.byModule = byModule
+	return nil
+}
+
+func (d *VulnDB) load() ([]*osvEntry, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to access vulnerability database '%s'", d.path)
+	}
+	if info.IsDir() {
+		return d.loadFS(os.DirFS(d.path))
+	}
+	archive, err := zip.OpenReader(d.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open vulnerability database '%s'", d.path)
+	}
+	defer utils.CloseAndLogError(archive)
+	return d.loadFS(archive)
+}
+
+// loadFS - parse all advisories stored as ID/<id>.json in given tree
+func (d *VulnDB) loadFS(fsys fs.FS) ([]*osvEntry, error) {
+	entries := []*osvEntry{}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(name) != ".json" || path.Base(path.Dir(name)) != "ID" {
+			return nil
+		}
+		file, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer utils.CloseAndLogError(file)
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		cEntry := osvEntry{}
+		if err := json.Unmarshal(content, &cEntry); err != nil {
+			return errors.Wrapf(err, "unable to parse advisory '%s'", name)
+		}
+		entries = append(entries, &cEntry)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read vulnerability database '%s'", d.path)
+	}
+	return entries, nil
+}
+
+// Match - advisories affecting given module version
+func (d *VulnDB) Match(modulePath string, version string) []Vulnerability {
+	if version == "" {
+		return nil
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	vulns := []Vulnerability{}
+	for _, cEntry := range d.byModule[modulePath] {
+		for _, cAffected := range cEntry.Affected {
+			if cAffected.Package.Name != modulePath || cAffected.Package.Ecosystem != osvEcosystem {
+				continue
+			}
+			if affected, fixed := affectsVersion(cAffected.Ranges, version); affected {
+				vuln := Vulnerability{
+					ID:       cEntry.ID,
+					Aliases:  cEntry.Aliases,
+					Summary:  cEntry.Summary,
+					Severity: cEntry.severity(),
+					Fixed:    fixed,
+				}
+				for _, cImport := range cAffected.EcosystemSpecific.Imports {
+					vuln.Imports = append(vuln.Imports, VulnImport{
+						Path:    cImport.Path,
+						Symbols: cImport.Symbols,
+					})
+				}
+				vulns = append(vulns, vuln)
+				break
+			}
+		}
+	}
+	return vulns
+}
+
+// affectsVersion - tells if version is within given SEMVER ranges, along with the version fixing it
+func affectsVersion(ranges []osvRange, version string) (bool, string) {
+	if len(ranges) == 0 {
+		return true, ""
+	}
+	for _, cRange := range ranges {
+		if cRange.Type != "SEMVER" {
+			continue
+		}
+		if affected, fixed := affectsRange(cRange.Events, version); affected {
+			return true, fixed
+		}
+	}
+	return false, ""
+}
+
+func affectsRange(events []osvEvent, version string) (bool, string) {
+	events = append([]osvEvent{}, events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return compareEvents(events[i], events[j]) < 0
+	})
+
+	affected := false
+	for _, cEvent := range events {
+		switch {
+		case !affected && cEvent.Introduced != "":
+			affected = cEvent.Introduced == "0" || semver.Compare(version, "v"+cEvent.Introduced) >= 0
+		case affected && cEvent.Fixed != "":
+			if semver.Compare(version, "v"+cEvent.Fixed) < 0 {
+				return true, "v" + cEvent.Fixed
+			}
+			affected = false
+		case affected && cEvent.LastAffected != "":
+			if semver.Compare(version, "v"+cEvent.LastAffected) <= 0 {
+				return true, ""
+			}
+			affected = false
+		}
+	}
+	return affected, ""
+}
+
+func compareEvents(a osvEvent, b osvEvent) int {
+	version := func(e osvEvent) string {
+		switch {
+		case e.Introduced == "0":
+			return ""
+		case e.Introduced != "":
+			return "v" + e.Introduced
+		case e.Fixed != "":
+			return "v" + e.Fixed
+		}
+		return "v" + e.LastAffected
+	}
+	va, vb := version(a), version(b)
+	switch {
+	case va == vb:
+		return 0
+	case va == "":
+		return -1
+	case vb == "":
+		return 1
+	}
+	return semver.Compare(va, vb)
+}
@@ -0,0 +1,207 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAffectsRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []osvEvent
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{
+			name:     "introduced from start, before fix",
+			events:   []osvEvent{{Introduced: "0"}, {Fixed: "1.2.0"}},
+			version:  "v1.1.9",
+			affected: true,
+			fixed:    "v1.2.0",
+		},
+		{
+			name:    "introduced from start, at fix",
+			events:  []osvEvent{{Introduced: "0"}, {Fixed: "1.2.0"}},
+			version: "v1.2.0",
+		},
+		{
+			name:    "before introduction",
+			events:  []osvEvent{{Introduced: "1.1.0"}, {Fixed: "1.2.0"}},
+			version: "v1.0.5",
+		},
+		{
+			name:     "at introduction",
+			events:   []osvEvent{{Introduced: "1.1.0"}, {Fixed: "1.2.0"}},
+			version:  "v1.1.0",
+			affected: true,
+			fixed:    "v1.2.0",
+		},
+		{
+			name:     "never fixed",
+			events:   []osvEvent{{Introduced: "1.1.0"}},
+			version:  "v3.0.0",
+			affected: true,
+		},
+		{
+			name:     "up to last affected",
+			events:   []osvEvent{{Introduced: "0"}, {LastAffected: "1.4.0"}},
+			version:  "v1.4.0",
+			affected: true,
+		},
+		{
+			name:    "after last affected",
+			events:  []osvEvent{{Introduced: "0"}, {LastAffected: "1.4.0"}},
+			version: "v1.4.1",
+		},
+		{
+			name:     "second of several ranges",
+			events:   []osvEvent{{Introduced: "2.0.0"}, {Fixed: "2.3.1"}, {Introduced: "0"}, {Fixed: "1.9.4"}},
+			version:  "v2.1.0",
+			affected: true,
+			fixed:    "v2.3.1",
+		},
+		{
+			name:    "between several ranges",
+			events:  []osvEvent{{Introduced: "0"}, {Fixed: "1.9.4"}, {Introduced: "2.0.0"}, {Fixed: "2.3.1"}},
+			version: "v1.9.5",
+		},
+		{
+			name:     "prerelease before fix",
+			events:   []osvEvent{{Introduced: "0"}, {Fixed: "1.2.0"}},
+			version:  "v1.2.0-rc.1",
+			affected: true,
+			fixed:    "v1.2.0",
+		},
+		{
+			name:     "pseudo-version before fix",
+			events:   []osvEvent{{Introduced: "0"}, {Fixed: "0.3.0"}},
+			version:  "v0.2.1-0.20200101000000-abcdefabcdef",
+			affected: true,
+			fixed:    "v0.3.0",
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			affected, fixed := affectsRange(cTest.events, cTest.version)
+			if affected != cTest.affected || fixed != cTest.fixed {
+				t.Errorf("expected (%v, %q), got (%v, %q)", cTest.affected, cTest.fixed, affected, fixed)
+			}
+		})
+	}
+}
+
+func TestAffectsVersion(t *testing.T) {
+	semverRange := osvRange{Type: "SEMVER", Events: []osvEvent{{Introduced: "0"}, {Fixed: "1.2.0"}}}
+	gitRange := osvRange{Type: "GIT", Events: []osvEvent{{Introduced: "0"}}}
+	tests := []struct {
+		name     string
+		ranges   []osvRange
+		affected bool
+	}{
+		{"no range affects all versions", nil, true},
+		{"semver range", []osvRange{semverRange}, true},
+		{"other range types are ignored", []osvRange{gitRange}, false},
+		{"any semver range", []osvRange{gitRange, semverRange}, true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if affected, _ := affectsVersion(cTest.ranges, "v1.0.0"); affected != cTest.affected {
+				t.Errorf("expected %v, got %v", cTest.affected, affected)
+			}
+		})
+	}
+}
+
+const testAdvisories = `{
+  "id": "GO-2020-0001",
+  "aliases": ["CVE-2020-0001"],
+  "summary": "first advisory",
+  "affected": [{
+    "package": {"name": "example.com/vulnerable", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}],
+    "ecosystem_specific": {"imports": [{"path": "example.com/vulnerable/pkg", "symbols": ["Parse"]}]}
+  }],
+  "database_specific": {"severity": "high"}
+}`
+
+const testOtherEcosystem = `{
+  "id": "GHSA-0000-0000-0000",
+  "affected": [{
+    "package": {"name": "example.com/vulnerable", "ecosystem": "npm"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]
+  }]
+}`
+
+const testWithdrawn = `{
+  "id": "GO-2020-0002",
+  "withdrawn": "2021-01-01T00:00:00Z",
+  "affected": [{
+    "package": {"name": "example.com/vulnerable", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]
+  }]
+}`
+
+func TestVulnDBMatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "ID"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"GO-2020-0001.json":        testAdvisories,
+		"GHSA-0000-0000-0000.json": testOtherEcosystem,
+		"GO-2020-0002.json":        testWithdrawn,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "ID", name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := NewVulnDB(&VulnConfig{Database: dir}, discard{})
+
+	tests := []struct {
+		name    string
+		module  string
+		version string
+		vulns   []Vulnerability
+	}{
+		{
+			name:    "affected version",
+			module:  "example.com/vulnerable",
+			version: "v1.1.0",
+			vulns: []Vulnerability{{
+				ID:       "GO-2020-0001",
+				Aliases:  []string{"CVE-2020-0001"},
+				Summary:  "first advisory",
+				Severity: "HIGH",
+				Fixed:    "v1.2.0",
+				Imports:  []VulnImport{{Path: "example.com/vulnerable/pkg", Symbols: []string{"Parse"}}},
+			}},
+		},
+		{
+			name:    "fixed version, other ecosystems and withdrawn advisories are ignored",
+			module:  "example.com/vulnerable",
+			version: "v1.2.0",
+			vulns:   []Vulnerability{},
+		},
+		{
+			name:    "unknown module",
+			module:  "example.com/other",
+			version: "v1.1.0",
+			vulns:   []Vulnerability{},
+		},
+		{
+			name:    "unknown version",
+			module:  "example.com/vulnerable",
+			version: "",
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			if vulns := db.Match(cTest.module, cTest.version); !reflect.DeepEqual(vulns, cTest.vulns) {
+				t.Errorf("expected %+v, got %+v", cTest.vulns, vulns)
+			}
+		})
+	}
+}
@@ -1,223 +1,160 @@
 package common
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"os/exec"
-	"strings"
+	"context"
 	"time"
 
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
 	"github.com/orange-cloudfoundry/gomod_exporter/utils"
-	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/mod/semver"
-	"gopkg.in/src-d/go-git.v4"
 )
 
-// Analyzer -
+// Analyzer - periodically analyze configured projects and send their reports to sinks
 type Analyzer struct {
-	config  *BaseConfig
-	metrics *Metrics
+	config   *BaseConfig
+	metrics  *Metrics
+	sinks    []Sink
+	state    *StateStore
+	analyzer *analysis.Analyzer
 }
 
 // NewAnalyzer -
 func NewAnalyzer(config *BaseConfig, metrics *Metrics) *Analyzer {
+	analyzer, err := analysis.New(config.AnalyzerConfig())
+	if err != nil {
+		log.Fatalf("invalid analysis configuration: %s", err)
+	}
+	sinks, err := NewSinks(config.Sinks)
+	if err != nil {
+		log.Fatalf("invalid sinks configuration: %s", err)
+	}
 	return &Analyzer{
-		config:  config,
-		metrics: metrics,
+		config:   config,
+		metrics:  metrics,
+		sinks:    sinks,
+		state:    NewStateStore(&config.State),
+		analyzer: analyzer,
 	}
 }
 
-// RunForever - starts endless analyze loop
-func (a *Analyzer) RunForever(interval time.Duration) {
+// Restore - expose persisted results of configured projects until they are analyzed again
+func (a *Analyzer) Restore() {
+	if a.state == nil {
+		return
+	}
+	results, err := a.state.Load()
+	if err != nil {
+		log.Errorf("unable to restore state: %s", err)
+		return
+	}
+	configured := map[string]bool{}
+	for _, cProject := range a.config.Projects {
+		configured[cProject.Key()] = true
+	}
+	for _, cResult := range results {
+		if !configured[cResult.key()] {
+			if err := a.state.Remove(cResult.key()); err != nil {
+				log.Warnf("%s", err)
+			}
+			continue
+		}
+		log.Infof("restoring results of %s analyzed at %s", cResult.key(), cResult.Time.Format(time.RFC3339))
+		a.metrics.Restore(cResult)
+	}
+}
+
+// RunForever - starts endless analyze loop, returned channel is closed once ctx is done
+// and the in-flight cycle has been cancelled
+func (a *Analyzer) RunForever(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for {
 			log.Infof("running full analyze")
 			start := time.Now()
-			for _, cProject := range a.config.Projects {
-				if err := a.ProcessProject(&cProject); err != nil {
-					log.Errorf("error processing project: %v", err)
-				}
-			}
+			a.RunOnce(ctx)
 			a.metrics.Duration.Set(time.Since(start).Seconds())
-			time.Sleep(interval)
+			select {
+			case <-ctx.Done():
+				log.Infof("analyze loop stopped: %s", ctx.Err())
+				return
+			case <-time.After(interval):
+			}
 		}
 	}()
+	return done
 }
 
-// ProcessProject - analyze a single project
-func (a *Analyzer) ProcessProject(config *GitConfig) error {
-	start := time.Now()
-	main, dependencies, replaces, err := a.analyzeProject(config)
-	if err != nil {
-		a.metrics.Status.WithLabelValues(config.URL).Set(float64(0))
-		a.metrics.Duration.Set(time.Since(start).Seconds())
-		return err
-	}
-	a.writeMetrics(config, main, dependencies, replaces)
-	a.metrics.Status.WithLabelValues(config.URL).Set(float64(1))
-	a.metrics.Duration.Set(time.Since(start).Seconds())
-	return nil
-}
-
-func (a *Analyzer) writeMetrics(
-	config *GitConfig,
-	main *ModulePublic,
-	deps []ModulePublic,
-	replaces []ModulePublic,
-) {
-	config.Entry().Debug("writing statistics")
-	a.metrics.Info.WithLabelValues(main.Path, main.GoVersion).Set(1)
-
-	for _, cDep := range replaces {
-		mType := "direct"
-		if cDep.Indirect {
-			mType = "indirect"
-		}
-		a.metrics.Replaced.WithLabelValues(
-			main.Path, cDep.Path, mType, cDep.Replace.Path, cDep.Replace.Version,
-		).Set(float64(1))
+// RunOnce - analyze all configured projects using a bounded pool of workers
+func (a *Analyzer) RunOnce(ctx context.Context) {
+	keys := []string{}
+	for _, cProject := range a.config.Projects {
+		keys = append(keys, cProject.Key())
 	}
-
-	for _, cDep := range deps {
-		mValue := float64(0)
-		mLatestVersion := cDep.Version
-		mType := "direct"
-		if cDep.Indirect {
-			mType = "indirect"
+	for _, cSink := range a.sinks {
+		if retainer, ok := cSink.(Retainer); ok {
+			retainer.Retain(keys)
 		}
-		if cDep.Update != nil {
-			mValue = 1000.0
-			mLatestVersion = cDep.Update.Version
-			if cDep.Time != nil && cDep.NextUpdate != nil && cDep.NextUpdate.Time != nil {
-				mValue = time.Since(*cDep.NextUpdate.Time).Hours() / 24.0
-			}
-		}
-		a.metrics.Deprecated.WithLabelValues(
-			main.Path, cDep.Path, mType,
-			cDep.Version, mLatestVersion,
-		).Set(mValue)
 	}
-}
-
-func (a *Analyzer) getRepository(config *GitConfig, dir string) error {
-	config.Entry().Debug("cloning repository")
-
-	_, err := git.PlainClone(dir, false, &git.CloneOptions{
-		URL:               config.URL,
-		SingleBranch:      true,
-		Depth:             1,
-		Auth:              config.AuthMethod(),
-		RecurseSubmodules: git.NoRecurseSubmodules,
-	})
-	if err != nil {
-		err = errors.Wrapf(err, "unable to checkout")
-		config.Entry().Errorf("%s", err.Error())
-		return err
+	if err := a.analyzer.Refresh(); err != nil {
+		log.Errorf("unable to refresh vulnerability database: %s", err)
 	}
-
-	return nil
-}
-
-func (a *Analyzer) getModules(config *GitConfig, dir string, project string) ([]ModulePublic, error) {
-	config.Entry().Debugf("extracting go modules for %s", project)
-	cmd := exec.Command("go", "list", "-versions", "-u", "-mod=mod", "-m", "-json", project)
-	cmd.Dir = dir
-	content, err := cmd.Output()
-	if err != nil {
-		if exerr, ok := err.(*exec.ExitError); ok {
-			config.Entry().Errorf("%s", string(exerr.Stderr))
+	utils.RunParallel(ctx, a.config.Analysis.ProjectWorkers, len(a.config.Projects), func(ctx context.Context, idx int) {
+		project := a.config.Projects[idx]
+		if _, err := a.ProcessProject(ctx, &project); err != nil {
+			log.Errorf("error processing project: %v", err)
 		}
-		err = errors.Wrap(err, "unable to run go analysis")
-		config.Entry().Errorf("%s", err.Error())
-		return nil, err
+	})
+	urls := []string{}
+	for _, cProject := range a.config.Projects {
+		urls = append(urls, cProject.URL)
 	}
-
-	jsonStr := string(content)
-	jsonStr = strings.ReplaceAll(jsonStr, "}\n{", "},\n{")
-	jsonStr = fmt.Sprintf("[%s]", jsonStr)
-	modules := []ModulePublic{}
-	err = json.Unmarshal([]byte(jsonStr), &modules)
-	if err != nil {
-		err = errors.Wrap(err, "unable to parse go list output")
-		config.Entry().Errorf("%s", err.Error())
-		return nil, err
+	if err := a.analyzer.Prune(urls); err != nil {
+		log.Errorf("unable to prune repository cache: %s", err)
 	}
-
-	return modules, nil
 }
 
-func (a *Analyzer) analyzeProject(config *GitConfig) (*ModulePublic, []ModulePublic, []ModulePublic, error) {
-	var main ModulePublic
-	deps := []ModulePublic{}
-	replaces := []ModulePublic{}
-
-	config.Entry().Info("analysing project")
-
-	if config.Dir == "" {
-		dir, err := os.MkdirTemp("", "git-checkout")
-		if err != nil {
-			err = errors.Wrap(err, "unable to create temp directory")
-			config.Entry().Errorf("%s", err.Error())
-			return nil, nil, nil, err
-		}
-		defer utils.RemoveDir(dir)
-		if err = a.getRepository(config, dir); err != nil {
-			return nil, nil, nil, err
+// ProcessProject - analyze a single project, report is returned even when analysis fails
+func (a *Analyzer) ProcessProject(ctx context.Context, config *GitConfig) (*analysis.Report, error) {
+	start := time.Now()
+	var report *analysis.Report
+	source, err := config.Source()
+	if err != nil {
+		config.Entry().Errorf("%s", err)
+		report = analysis.NewFailedReport(config.URL, start, err)
+		report.Ref = config.Ref
+	} else {
+		// last successful report, persisted ones included, enables incremental analysis
+		if result, ok := a.metrics.Result(config.Key()); ok {
+			source.Previous = result.Report
 		}
-		config.Dir = dir
+		report, err = a.analyzer.Analyze(ctx, source)
 	}
-
-	modules, err := a.getModules(config, config.Dir, "all")
-	if err != nil {
-		return nil, nil, nil, err
+	// an interrupted analysis says nothing about the project, previous results are kept
+	if ctx.Err() != nil {
+		config.Entry().Infof("analysis cancelled, keeping previous results: %s", ctx.Err())
+		return report, ctx.Err()
 	}
-
-	for _, cModule := range modules {
-		if cModule.Main {
-			main = cModule
-			continue
-		}
-
-		if cModule.Replace != nil {
-			replaces = append(replaces, cModule)
-			cModule = *cModule.Replace
+	config.Entry().Debug("sending report")
+	for _, cSink := range a.sinks {
+		if sErr := cSink.Send(ctx, report); sErr != nil {
+			config.Entry().Warnf("unable to send report to %T: %s", cSink, sErr)
 		}
-
-		config.Entry().Debugf("analyzing dependency: %s", cModule.Path)
-		if cModule.Update == nil {
-			cModule.NextUpdate = nil
-		} else {
-			cModule.NextUpdate = cModule.Update
-			nextVersion, isLast := a.getNextVersion(&cModule)
-			if !isLast {
-				name := fmt.Sprintf("%s@%s", cModule.Path, nextVersion)
-				depModules, err := a.getModules(config, config.Dir, name)
-				if err != nil {
-					cModule.NextUpdate = nil
-					config.Entry().Warnf("could not analyze dependency %s, inaccurate deprecation date: %s", name, err)
-				} else {
-					cModule.NextUpdate = &(depModules[0])
-				}
-			}
-		}
-		deps = append(deps, cModule)
 	}
-	return &main, deps, replaces, nil
+	a.metrics.Duration.Set(time.Since(start).Seconds())
+	a.saveState(config)
+	return report, err
 }
 
-func (a *Analyzer) getNextVersion(module *ModulePublic) (string, bool) {
-	if len(module.Versions) == 0 {
-		return module.Update.Version, true
+func (a *Analyzer) saveState(config *GitConfig) {
+	if a.state == nil {
+		return
 	}
-
-	current := module.Version
-	for cIdx, cVersion := range module.Versions {
-		if semver.Compare(cVersion, current) > 0 {
-			isLast := cIdx == (len(module.Versions) - 1)
-			return cVersion, isLast
-		}
+	result, ok := a.metrics.Result(config.Key())
+	if !ok {
+		return
+	}
+	if err := a.state.Save(&result); err != nil {
+		config.Entry().Warnf("unable to persist analysis state: %s", err)
 	}
-	return current, false
 }
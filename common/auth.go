@@ -0,0 +1,391 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// GitAuth - credentials of git project, secrets are given inline or read from environment
+// variables and files each time project is analyzed so rotated secrets are picked up
+type GitAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordEnv  string `yaml:"password_env"`  // environment variable holding password
+	PasswordFile string `yaml:"password_file"` // file holding password, such as a mounted secret
+	// bearer token sent instead of username and password
+	Token     string `yaml:"token"`
+	TokenEnv  string `yaml:"token_env"`
+	TokenFile string `yaml:"token_file"`
+	// look credentials of project host up in NETRC or ~/.netrc when no password nor token is given
+	Netrc bool `yaml:"netrc"`
+	// ask credentials to configured git credential helpers when nothing else is found
+	CredentialHelper bool     `yaml:"credential_helper"`
+	SSH              *SSHAuth `yaml:"ssh"`
+}
+
+// SSHAuth - private key authentication of ssh:// and scp-like urls, keys of the running
+// ssh-agent are used when no key is given
+type SSHAuth struct {
+	KeyFile        string `yaml:"key_file"`        // path of private key
+	Key            string `yaml:"key"`             // inline private key, exclusive with key_file
+	Passphrase     string `yaml:"passphrase"`      // passphrase of encrypted private key, if any
+	PassphraseEnv  string `yaml:"passphrase_env"`  // environment variable holding passphrase
+	PassphraseFile string `yaml:"passphrase_file"` // file holding passphrase
+	// known_hosts files checked against server host key, defaults to SSH_KNOWN_HOSTS or
+	// ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts
+	KnownHosts []string `yaml:"known_hosts"`
+}
+
+// secret - resolve secret from inline value, environment variable or file, in this order
+func secret(value string, env string, file string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if env != "" {
+		if value = os.Getenv(env); value == "" {
+			return "", fmt.Errorf("environment variable %s is empty", env)
+		}
+		return value, nil
+	}
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file: %s", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	return "", nil
+}
+
+// exclusiveSecret - error when given secret is set from more than one source
+func exclusiveSecret(name string, value string, env string, file string) error {
+	count := 0
+	for _, cSource := range []string{value, env, file} {
+		if cSource != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("%s, %[1]s_env and %[1]s_file are mutually exclusive", name)
+	}
+	return nil
+}
+
+// isSSH - tells if given git url is reached through ssh
+func isSSH(url string) bool {
+	endpoint, err := transport.NewEndpoint(url)
+	return err == nil && endpoint.Protocol == "ssh"
+}
+
+// sshUser - user of ssh connection, from url first then from configuration
+func (c *GitConfig) sshUser() string {
+	if endpoint, err := transport.NewEndpoint(c.URL); err == nil && endpoint.User != "" {
+		return endpoint.User
+	}
+	if c.Auth != nil && c.Auth.Username != "" {
+		return c.Auth.Username
+	}
+	return "git"
+}
+
+// AuthMethod - create transport Auth handler
+func (c *GitConfig) AuthMethod() (transport.AuthMethod, error) {
+	if isSSH(c.URL) {
+		auth := &SSHAuth{}
+		if c.Auth != nil && c.Auth.SSH != nil {
+			auth = c.Auth.SSH
+		}
+		return auth.method(c.sshUser())
+	}
+	if c.Auth == nil {
+		return nil, nil
+	}
+	return c.Auth.httpMethod(c.URL)
+}
+
+// validate - check that credentials are consistent, without reading any secret
+func (a *GitAuth) validate() error {
+	if a == nil {
+		return nil
+	}
+	if err := exclusiveSecret("token", a.Token, a.TokenEnv, a.TokenFile); err != nil {
+		return err
+	}
+	if err := exclusiveSecret("password", a.Password, a.PasswordEnv, a.PasswordFile); err != nil {
+		return err
+	}
+	hasToken := a.Token != "" || a.TokenEnv != "" || a.TokenFile != ""
+	if hasToken && (a.Password != "" || a.PasswordEnv != "" || a.PasswordFile != "") {
+		return fmt.Errorf("token and password are mutually exclusive")
+	}
+	if a.SSH != nil {
+		return a.SSH.validate()
+	}
+	return nil
+}
+
+// kind - name of authentication used for given url, never includes any secret
+func (a *GitAuth) kind(rawURL string) string {
+	switch {
+	case isSSH(rawURL):
+		return "ssh"
+	case a == nil:
+		return "(no-auth)"
+	case a.Token != "" || a.TokenEnv != "" || a.TokenFile != "":
+		return "token"
+	case a.Username != "" || a.Password != "" || a.PasswordEnv != "" || a.PasswordFile != "":
+		return "basic"
+	case a.Netrc:
+		return "netrc"
+	case a.CredentialHelper:
+		return "credential-helper"
+	}
+	return "(no-auth)"
+}
+
+func (a *GitAuth) httpMethod(rawURL string) (transport.AuthMethod, error) {
+	token, err := secret(a.Token, a.TokenEnv, a.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %s", err)
+	}
+	if token != "" {
+		return &http.TokenAuth{Token: token}, nil
+	}
+	password, err := secret(a.Password, a.PasswordEnv, a.PasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password: %s", err)
+	}
+	if a.Username != "" || password != "" {
+		return &http.BasicAuth{Username: a.Username, Password: password}, nil
+	}
+	if !a.Netrc && !a.CredentialHelper {
+		return nil, nil
+	}
+
+	endpoint, err := transport.NewEndpoint(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %s", err)
+	}
+	if a.Netrc {
+		username, password, err := netrcLogin(endpoint.Host)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			return &http.BasicAuth{Username: username, Password: password}, nil
+		}
+	}
+	if a.CredentialHelper {
+		username, password, err := credentialFill(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return &http.BasicAuth{Username: username, Password: password}, nil
+	}
+	return nil, fmt.Errorf("no netrc entry found for %s", endpoint.Host)
+}
+
+// netrcLogin - login and password of given host from netrc file, default entry is used when
+// host is not listed
+func netrcLogin(host string) (string, string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("unable to locate netrc: %s", err)
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to read netrc: %s", err)
+	}
+	defer utils.CloseAndLogError(file)
+
+	type entry struct {
+		machine  string
+		login    string
+		password string
+	}
+	var (
+		entries []entry
+		current *entry
+		macro   bool
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// macro definitions span until next empty line
+		if macro {
+			macro = len(fields) != 0
+			continue
+		}
+		for cIdx := 0; cIdx < len(fields); cIdx++ {
+			value := ""
+			if cIdx+1 < len(fields) {
+				value = fields[cIdx+1]
+			}
+			switch fields[cIdx] {
+			case "machine":
+				entries = append(entries, entry{machine: value})
+				current = &entries[len(entries)-1]
+				cIdx++
+			case "default":
+				entries = append(entries, entry{})
+				current = &entries[len(entries)-1]
+			case "login":
+				if current != nil {
+					current.login = value
+				}
+				cIdx++
+			case "password":
+				if current != nil {
+					current.password = value
+				}
+				cIdx++
+			case "account":
+				cIdx++
+			case "macdef":
+				macro = true
+				cIdx = len(fields)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("unable to read netrc: %s", err)
+	}
+
+	var fallback *entry
+	for cIdx := range entries {
+		if entries[cIdx].machine == host {
+			return entries[cIdx].login, entries[cIdx].password, nil
+		}
+		if entries[cIdx].machine == "" && fallback == nil {
+			fallback = &entries[cIdx]
+		}
+	}
+	if fallback != nil {
+		return fallback.login, fallback.password, nil
+	}
+	return "", "", nil
+}
+
+// credentialFill - ask credentials of given endpoint to git credential helpers, without ever
+// prompting on terminal
+func credentialFill(endpoint *transport.Endpoint) (string, string, error) {
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host = fmt.Sprintf("%s:%d", host, endpoint.Port)
+	}
+	request := fmt.Sprintf("protocol=%s\nhost=%s\npath=%s\n", endpoint.Protocol, host, strings.TrimPrefix(endpoint.Path, "/"))
+	if endpoint.User != "" {
+		request += fmt.Sprintf("username=%s\n", endpoint.User)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(request + "\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git credential helper failed: %s", err)
+	}
+
+	var username, password string
+	for _, cLine := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(cLine, "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("no credentials found by git credential helper for %s", host)
+	}
+	return username, password, nil
+}
+
+// validate - check that ssh settings are consistent, without reading key nor passphrase
+func (a *SSHAuth) validate() error {
+	if a.Key != "" && a.KeyFile != "" {
+		return fmt.Errorf("ssh key and key_file are mutually exclusive")
+	}
+	if err := exclusiveSecret("passphrase", a.Passphrase, a.PassphraseEnv, a.PassphraseFile); err != nil {
+		return err
+	}
+	hasPassphrase := a.Passphrase != "" || a.PassphraseEnv != "" || a.PassphraseFile != ""
+	if hasPassphrase && a.Key == "" && a.KeyFile == "" {
+		return fmt.Errorf("ssh passphrase given without key")
+	}
+	for _, cPath := range a.KnownHosts {
+		if cPath == "" {
+			return fmt.Errorf("empty known_hosts path")
+		}
+	}
+	return nil
+}
+
+// hostKeyCallback - strict host key checking against configured known_hosts files
+func (a *SSHAuth) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if len(a.KnownHosts) == 0 {
+		return gitssh.NewKnownHostsCallback()
+	}
+	return knownhosts.New(a.KnownHosts...)
+}
+
+func (a *SSHAuth) method(user string) (transport.AuthMethod, error) {
+	callback, err := a.hostKeyCallback()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load known_hosts: %s", err)
+	}
+
+	key := []byte(a.Key)
+	if a.KeyFile != "" {
+		if key, err = os.ReadFile(a.KeyFile); err != nil {
+			return nil, fmt.Errorf("unable to read ssh key: %s", err)
+		}
+	}
+	if len(key) == 0 {
+		agent, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, err
+		}
+		agent.HostKeyCallback = callback
+		return agent, nil
+	}
+
+	passphrase, err := secret(a.Passphrase, a.PassphraseEnv, a.PassphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh passphrase: %s", err)
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ssh key: %s", err)
+	}
+	return &gitssh.PublicKeys{
+		User:                  user,
+		Signer:                signer,
+		HostKeyCallbackHelper: gitssh.HostKeyCallbackHelper{HostKeyCallback: callback},
+	}, nil
+}
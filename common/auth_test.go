@@ -0,0 +1,196 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+const testNetrc = `machine git.example.com login alice password s3cret
+# comment lines are not special to netrc, tokens are simply skipped
+machine other.example.com
+  login bob
+  account ignored
+  password hunter2
+
+macdef init
+machine macro.example.com login mallory password nope
+
+machine after-macro.example.com login carol password p4ss
+default login anonymous password guest
+machine late.example.com login dave password d4ve
+`
+
+func TestNetrcLogin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte(testNetrc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", path)
+
+	tests := []struct {
+		host     string
+		login    string
+		password string
+	}{
+		{"git.example.com", "alice", "s3cret"},
+		{"other.example.com", "bob", "hunter2"},
+		{"macro.example.com", "anonymous", "guest"},
+		{"after-macro.example.com", "carol", "p4ss"},
+		{"late.example.com", "dave", "d4ve"},
+		{"unknown.example.com", "anonymous", "guest"},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.host, func(t *testing.T) {
+			login, password, err := netrcLogin(cTest.host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if login != cTest.login || password != cTest.password {
+				t.Errorf("expected %s:%s, got %s:%s", cTest.login, cTest.password, login, password)
+			}
+		})
+	}
+}
+
+func TestNetrcLoginWithoutDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte("machine git.example.com login alice password s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", path)
+
+	login, password, err := netrcLogin("unknown.example.com")
+	if err != nil || login != "" || password != "" {
+		t.Errorf("expected no credentials, got %q, %q, %v", login, password, err)
+	}
+
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+	if _, _, err := netrcLogin("git.example.com"); err == nil {
+		t.Errorf("expected error for missing netrc file")
+	}
+}
+
+func TestGitAuthValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		auth *GitAuth
+		err  bool
+	}{
+		{"no auth", nil, false},
+		{"basic", &GitAuth{Username: "bot", PasswordFile: "/run/secrets/missing"}, false},
+		{"token", &GitAuth{TokenEnv: "GOMOD_UNSET_TOKEN"}, false},
+		{"two password sources", &GitAuth{Password: "inline", PasswordEnv: "GOMOD_PASSWORD"}, true},
+		{"two token sources", &GitAuth{TokenEnv: "GOMOD_TOKEN", TokenFile: "/run/secrets/token"}, true},
+		{"token and password", &GitAuth{Token: "token", PasswordFile: "/run/secrets/password"}, true},
+		{"ssh key file", &GitAuth{SSH: &SSHAuth{KeyFile: "/missing/id_ed25519", PassphraseEnv: "GOMOD_PASSPHRASE"}}, false},
+		{"ssh key and key file", &GitAuth{SSH: &SSHAuth{Key: "inline", KeyFile: "/missing/id_ed25519"}}, true},
+		{"ssh passphrase without key", &GitAuth{SSH: &SSHAuth{Passphrase: "secret"}}, true},
+		{"ssh empty known hosts", &GitAuth{SSH: &SSHAuth{KnownHosts: []string{""}}}, true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			err := cTest.auth.validate()
+			if cTest.err && err == nil {
+				t.Errorf("expected error")
+			}
+			if !cTest.err && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSSHAuthMethod(t *testing.T) {
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := string(pem.EncodeToMemory(block))
+	if block, err = ssh.MarshalPrivateKeyWithPassphrase(clientKey, "", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey := string(pem.EncodeToMemory(block))
+
+	hostPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewPublicKey(hostPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(otherPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{"git.example.com"}, hostKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMOD_TEST_PASSPHRASE", "secret")
+
+	tests := []struct {
+		name string
+		url  string
+		auth *SSHAuth
+		user string
+		err  bool
+	}{
+		{"scp-like url", "git@git.example.com:org/repo.git", &SSHAuth{Key: key, KnownHosts: []string{knownHosts}}, "git", false},
+		{"user of url", "ssh://deploy@git.example.com/org/repo.git", &SSHAuth{Key: key, KnownHosts: []string{knownHosts}}, "deploy", false},
+		{"encrypted key", "git@git.example.com:org/repo.git", &SSHAuth{Key: encryptedKey, PassphraseEnv: "GOMOD_TEST_PASSPHRASE", KnownHosts: []string{knownHosts}}, "git", false},
+		{"wrong passphrase", "git@git.example.com:org/repo.git", &SSHAuth{Key: encryptedKey, Passphrase: "wrong", KnownHosts: []string{knownHosts}}, "", true},
+		{"missing passphrase", "git@git.example.com:org/repo.git", &SSHAuth{Key: encryptedKey, KnownHosts: []string{knownHosts}}, "", true},
+		{"missing known_hosts", "git@git.example.com:org/repo.git", &SSHAuth{Key: key, KnownHosts: []string{knownHosts + ".missing"}}, "", true},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			config := GitConfig{URL: cTest.url, Auth: &GitAuth{SSH: cTest.auth}}
+			method, err := config.AuthMethod()
+			if cTest.err {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			keys, ok := method.(*gitssh.PublicKeys)
+			if !ok {
+				t.Fatalf("expected public keys authentication, got %T", method)
+			}
+			if keys.User != cTest.user {
+				t.Errorf("expected user %s, got %s", cTest.user, keys.User)
+			}
+			remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+			callback := keys.HostKeyCallback
+			if err := callback("git.example.com:22", remote, hostKey); err != nil {
+				t.Errorf("expected known host key to be accepted: %s", err)
+			}
+			if err := callback("git.example.com:22", remote, otherKey); err == nil {
+				t.Errorf("expected changed host key to be rejected")
+			}
+			if err := callback("unknown.example.com:22", remote, hostKey); err == nil {
+				t.Errorf("expected unknown host to be rejected")
+			}
+		})
+	}
+}
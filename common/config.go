@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,19 +23,78 @@ type LogConfig struct {
 
 // GitConfig -
 type GitConfig struct {
-	URL  string   `yaml:"url"`
-	Auth *GitAuth `yaml:"auth"`
-	Dir  string
+	URL       string                    `yaml:"url"`
+	Ref       string                    `yaml:"ref"`  // branch, tag, commit hash or semver tag glob, default branch when empty
+	Refs      []string                  `yaml:"refs"` // several refs, each one analyzed and exported on its own
+	Auth      *GitAuth                  `yaml:"auth"`
+	Staleness *analysis.StalenessConfig `yaml:"staleness"`
+	Ignore    []analysis.IgnoreRule     `yaml:"ignore"`
+	Dir       string
 }
 
-// GitAuth -
-type GitAuth struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+// expand - one project per configured ref
+func (c GitConfig) expand() ([]GitConfig, error) {
+	if len(c.Refs) == 0 {
+		return []GitConfig{c}, nil
+	}
+	if c.Ref != "" {
+		return nil, fmt.Errorf("project %s gives both ref and refs", utils.RedactURL(c.URL))
+	}
+	projects := []GitConfig{}
+	for _, cRef := range c.Refs {
+		project := c
+		project.Ref = cRef
+		project.Refs = nil
+		projects = append(projects, project)
+	}
+	return projects, nil
 }
 
-func (c *GitConfig) validate() error {
-	return nil
+// Key - identifier of project in state, metrics and sinks, distinct for each ref of a repository
+func (c *GitConfig) Key() string {
+	return projectKey(c.URL, c.Ref)
+}
+
+// projectKey - identifier of a project, credentials of repository are hidden like in reports so
+// that it is safe for logs and file names
+func projectKey(repository string, ref string) string {
+	repository = utils.RedactURL(repository)
+	if ref == "" {
+		return repository
+	}
+	return repository + "@" + ref
+}
+
+// validate - check project settings, secrets of its credentials are only resolved when it is
+// analyzed so that a missing one fails this project alone
+func (c *GitConfig) validate(defaults *analysis.Config) error {
+	if err := c.Auth.validate(); err != nil {
+		return fmt.Errorf("invalid authentication of %s: %s", utils.RedactURL(c.URL), err)
+	}
+	source := c.source()
+	return defaults.ValidateSource(&source)
+}
+
+// Source - analysis source of the project, with credentials resolved
+func (c *GitConfig) Source() (analysis.Source, error) {
+	source := c.source()
+	auth, err := c.AuthMethod()
+	if err != nil {
+		return analysis.Source{}, fmt.Errorf("invalid authentication of %s: %s", utils.RedactURL(c.URL), err)
+	}
+	source.Auth = auth
+	return source, nil
+}
+
+func (c *GitConfig) source() analysis.Source {
+	return analysis.Source{
+		URL:       c.URL,
+		Ref:       c.Ref,
+		Dir:       c.Dir,
+		Staleness: c.Staleness,
+		Ignore:    c.Ignore,
+		Logger:    c.Entry(),
+	}
 }
 
 // Entry - generate log entry for current object
@@ -42,22 +103,67 @@ func (c *GitConfig) Entry() *log.Entry {
 	if c.Auth != nil && c.Auth.Username != "" {
 		username = c.Auth.Username
 	}
-	return log.WithFields(log.Fields{
-		"url":      c.URL,
+	fields := log.Fields{
+		"url":      utils.RedactURL(c.URL),
 		"username": username,
-	})
+		"auth":     c.Auth.kind(c.URL),
+	}
+	if c.Ref != "" {
+		fields["ref"] = c.Ref
+	}
+	return log.WithFields(fields)
 }
 
-// AuthMethod - create transport Auth handler
-func (c *GitConfig) AuthMethod() transport.AuthMethod {
-	if c.Auth == nil {
-		return nil
-	}
+// AnalysisConfig - concurrency and per-step timeouts of analysis
+type AnalysisConfig struct {
+	ProjectWorkers    int    `yaml:"project_workers"`
+	DependencyWorkers int    `yaml:"dependency_workers"`
+	CloneTimeout      string `yaml:"clone_timeout"`
+	ListTimeout       string `yaml:"list_timeout"`
+	ProxyTimeout      string `yaml:"proxy_timeout"`
+	// number of most recent releases used to compute release cadence of dependencies
+	CadenceReleases int `yaml:"cadence_releases"`
 
-	return &http.BasicAuth{
-		Username: c.Auth.Username,
-		Password: c.Auth.Password,
+	cloneDuration time.Duration
+	listDuration  time.Duration
+	proxyDuration time.Duration
+}
+
+func (c *AnalysisConfig) validate() error {
+	if c.ProjectWorkers <= 0 {
+		c.ProjectWorkers = 4
+	}
+	if c.DependencyWorkers <= 0 {
+		c.DependencyWorkers = 8
 	}
+	if c.CadenceReleases <= 0 {
+		c.CadenceReleases = 5
+	}
+	if len(c.CloneTimeout) == 0 {
+		c.CloneTimeout = "5m"
+	}
+	if len(c.ListTimeout) == 0 {
+		c.ListTimeout = "10m"
+	}
+	if len(c.ProxyTimeout) == 0 {
+		c.ProxyTimeout = "30s"
+	}
+	durations := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{c.CloneTimeout, &c.cloneDuration},
+		{c.ListTimeout, &c.listDuration},
+		{c.ProxyTimeout, &c.proxyDuration},
+	}
+	for _, cDuration := range durations {
+		val, err := time.ParseDuration(cDuration.value)
+		if err != nil {
+			return fmt.Errorf("invalid timeout value '%s': %s", cDuration.value, err)
+		}
+		*cDuration.target = val
+	}
+	return nil
 }
 
 // Config - Interface
@@ -67,16 +173,82 @@ type Config interface {
 
 // BaseConfig -
 type BaseConfig struct {
-	Log      LogConfig   `yaml:"log"`
-	Projects []GitConfig `yaml:"projects"`
+	Log             LogConfig                `yaml:"log"`
+	Proxy           analysis.ProxyConfig     `yaml:"proxy"`
+	Analysis        AnalysisConfig           `yaml:"analysis"`
+	State           StateConfig              `yaml:"state"`
+	Cache           analysis.CacheConfig     `yaml:"cache"`
+	Vulnerabilities analysis.VulnConfig      `yaml:"vulnerabilities"`
+	Staleness       analysis.StalenessConfig `yaml:"staleness"`
+	Ignore          []analysis.IgnoreRule    `yaml:"ignore"`
+	Policies        []analysis.PolicyRule    `yaml:"policies"`
+	Sinks           []SinkConfig             `yaml:"sinks"`
+	Projects        []GitConfig              `yaml:"projects"`
+}
+
+// AnalyzerConfig - settings of the analysis library
+func (c *BaseConfig) AnalyzerConfig() analysis.Config {
+	return analysis.Config{
+		Proxy:             c.Proxy,
+		Vulnerabilities:   c.Vulnerabilities,
+		Cache:             c.Cache,
+		Staleness:         c.Staleness,
+		Ignore:            c.Ignore,
+		Policies:          c.Policies,
+		DependencyWorkers: c.Analysis.DependencyWorkers,
+		CadenceReleases:   c.Analysis.CadenceReleases,
+		CloneTimeout:      c.Analysis.cloneDuration,
+		ListTimeout:       c.Analysis.listDuration,
+		ProxyTimeout:      c.Analysis.proxyDuration,
+		Logger:            log.StandardLogger(),
+	}
 }
 
 // Validate - Validate configuration object
 func (c *BaseConfig) Validate() error {
+	if err := c.Analysis.validate(); err != nil {
+		return fmt.Errorf("invalid analysis configuration: %s", err)
+	}
+	if err := c.State.validate(); err != nil {
+		return fmt.Errorf("invalid state configuration: %s", err)
+	}
+	config := c.AnalyzerConfig()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	// keep defaults and compiled rules of validated analysis settings
+	c.Proxy = config.Proxy
+	c.Vulnerabilities = config.Vulnerabilities
+	c.Staleness = config.Staleness
+	// gauges are always fed, they hold the results reused by incremental analyses and state
+	if !slices.ContainsFunc(c.Sinks, func(sink SinkConfig) bool { return sink.Type == "prometheus" }) {
+		c.Sinks = append([]SinkConfig{{Type: "prometheus"}}, c.Sinks...)
+	}
+	for cIdx := range c.Sinks {
+		if err := c.Sinks[cIdx].validate(); err != nil {
+			return fmt.Errorf("invalid sinks configuration: %s", err)
+		}
+	}
+	projects := []GitConfig{}
 	for _, cProject := range c.Projects {
-		if err := cProject.validate(); err != nil {
+		expanded, err := cProject.expand()
+		if err != nil {
 			return fmt.Errorf("invalid bosh configuration: %s", err)
 		}
+		projects = append(projects, expanded...)
+	}
+	c.Projects = projects
+	// projects are exported under their repository without credentials, which must identify them
+	keys := map[string]bool{}
+	for cIdx := range c.Projects {
+		if err := c.Projects[cIdx].validate(&config); err != nil {
+			return fmt.Errorf("invalid bosh configuration: %s", err)
+		}
+		key := c.Projects[cIdx].Key()
+		if keys[key] {
+			return fmt.Errorf("invalid bosh configuration: project %s is configured twice", key)
+		}
+		keys[key] = true
 	}
 	return nil
 }
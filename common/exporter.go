@@ -1,80 +1,456 @@
 package common
 
 import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/mod/semver"
 )
 
-// Metrics - hold metrics and initialized registry
+type projectState struct {
+	result ProjectResult
+	// result was loaded from persisted state and not yet refreshed
+	restored bool
+}
+
+// Metrics - prometheus collector exposing the last successful report of each project
 type Metrics struct {
-	Info       *prometheus.GaugeVec
-	Deprecated *prometheus.GaugeVec
-	Replaced   *prometheus.GaugeVec
-	Status     *prometheus.GaugeVec
-	Duration   prometheus.Gauge
-	Registry   *prometheus.Registry
+	Duration prometheus.Gauge
+	Registry *prometheus.Registry
+
+	info            *prometheus.Desc
+	deprecated      *prometheus.Desc
+	replaced        *prometheus.Desc
+	status          *prometheus.Desc
+	analyzed        *prometheus.Desc
+	incremental     *prometheus.Desc
+	deprecation     *prometheus.Desc
+	retracted       *prometheus.Desc
+	vulnerability   *prometheus.Desc
+	vulnerabilities *prometheus.Desc
+	versionsBehind  *prometheus.Desc
+	versionLag      *prometheus.Desc
+	majorUpdate     *prometheus.Desc
+	versionAge      *prometheus.Desc
+	latestAge       *prometheus.Desc
+	releaseInterval *prometheus.Desc
+	staleness       *prometheus.Desc
+	stalenessCount  *prometheus.Desc
+	expiredRules    *prometheus.Desc
+	policyCount     *prometheus.Desc
+	policyViolation *prometheus.Desc
+
+	mutex    sync.RWMutex
+	projects map[string]*projectState
 }
 
 // NewMetrics - create Metrics object
 func NewMetrics(ns string) *Metrics {
 	res := &Metrics{
-		Info: promauto.NewGaugeVec(
+		Duration: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: ns,
-				Name:      "info",
-				Help:      "Informations about given repository, value always 1",
+				Name:      "duration",
+				Help:      "Duration of last analysis in second",
 			},
-			[]string{"module", "goversion"},
 		),
-		Deprecated: promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: ns,
-				Name:      "deprecated",
-				Help:      "Number of days since given dependency of repository is out-of-date",
-			},
-			[]string{"module", "dependency", "type", "current", "latest"},
+		Registry: prometheus.NewRegistry(),
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "info"),
+			"Informations about given repository, value always 1",
+			[]string{"module", "repository", "ref", "goversion"}, nil,
 		),
-		Replaced: promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: ns,
-				Name:      "replaced",
-				Help:      "Give information about module replacements",
-			},
-			[]string{"module", "dependency", "type", "replacement", "version"},
+		deprecated: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "deprecated"),
+			"Number of days since given dependency of repository is out-of-date",
+			[]string{"module", "repository", "ref", "dependency", "type", "current", "latest", "update_kind", "pseudo", "incompatible", "ignored"}, nil,
 		),
-		Status: promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: ns,
-				Name:      "status",
-				Help:      "Status of last analysis of given repository, 0 for error",
-			},
-			[]string{"repository"},
+		replaced: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "replaced"),
+			"Give information about module replacements",
+			[]string{"module", "repository", "ref", "dependency", "type", "replacement", "version"}, nil,
 		),
-		Duration: promauto.NewGauge(
-			prometheus.GaugeOpts{
-				Namespace: ns,
-				Name:      "duration",
-				Help:      "Duration of last analysis in second",
-			},
+		status: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "status"),
+			"Status of last analysis of given repository, 0 for error",
+			[]string{"repository", "ref"}, nil,
 		),
-		Registry: prometheus.NewRegistry(),
+		analyzed: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "analysis_timestamp"),
+			"Unix time of the analysis currently exposed for given repository",
+			[]string{"repository", "ref", "restored"}, nil,
+		),
+		incremental: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "analysis_incremental"),
+			"1 when analysis currently exposed for given repository only refreshed upstream versions of unchanged sources, 0 for a full analysis",
+			[]string{"repository", "ref"}, nil,
+		),
+		deprecation: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "deprecation"),
+			"Deprecation notice published by given dependency, value always 1",
+			[]string{"module", "repository", "ref", "dependency", "type", "message", "replacement"}, nil,
+		),
+		retracted: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "retracted"),
+			"Used version of given dependency is retracted, value always 1",
+			[]string{"module", "repository", "ref", "dependency", "type", "version", "rationale"}, nil,
+		),
+		vulnerability: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "vulnerability"),
+			"Known advisory affecting used version of given dependency, value always 1",
+			[]string{"module", "repository", "ref", "dependency", "type", "version", "id", "severity", "fixed", "reachable"}, nil,
+		),
+		vulnerabilities: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "vulnerabilities"),
+			"Number of known advisories affecting dependencies of given repository",
+			[]string{"module", "repository", "ref"}, nil,
+		),
+		versionsBehind: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "versions_behind"),
+			"Number of releases of given dependency between current and latest version",
+			[]string{"module", "repository", "ref", "dependency", "type", "update_kind"}, nil,
+		),
+		versionLag: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "version_lag"),
+			"Delta of given semver component between current and latest version, lower components are 0 when a higher one differs",
+			[]string{"module", "repository", "ref", "dependency", "type", "update_kind", "component"}, nil,
+		),
+		majorUpdate: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "major_update_timestamp"),
+			"Unix release time of latest version of the highest newer major module path of given dependency",
+			[]string{"module", "repository", "ref", "dependency", "type", "path", "version", "major"}, nil,
+		),
+		versionAge: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "version_age"),
+			"Number of days since used version of given dependency was released",
+			[]string{"module", "repository", "ref", "dependency", "type", "version"}, nil,
+		),
+		latestAge: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "latest_release_age"),
+			"Number of days since latest version of given dependency was released",
+			[]string{"module", "repository", "ref", "dependency", "type", "version"}, nil,
+		),
+		releaseInterval: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "release_interval"),
+			"Average number of days between recent releases of given dependency",
+			[]string{"module", "repository", "ref", "dependency", "type", "releases"}, nil,
+		),
+		staleness: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "dependency_state"),
+			"Release activity state of given dependency, value always 1",
+			[]string{"module", "repository", "ref", "dependency", "type", "state"}, nil,
+		),
+		stalenessCount: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "dependencies_by_state"),
+			"Number of dependencies of given repository in given release activity state",
+			[]string{"module", "repository", "ref", "state"}, nil,
+		),
+		expiredRules: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "expired_ignore_rules"),
+			"Number of ignore rules applying to given repository that are past their expiry date",
+			[]string{"module", "repository", "ref"}, nil,
+		),
+		policyCount: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "policy_violations"),
+			"Number of dependencies of given repository breaking given policy rule",
+			[]string{"module", "repository", "ref", "policy"}, nil,
+		),
+		policyViolation: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", "policy_violation"),
+			"Dependency of given repository breaking given policy rule, value always 1",
+			[]string{"module", "repository", "ref", "policy", "dependency", "type", "version", "reason"}, nil,
+		),
+		projects: map[string]*projectState{},
 	}
 
-	if err := res.Registry.Register(res.Info); err != nil {
-		log.Errorf("unable to register info metric: %s", err)
+	if err := res.Registry.Register(res); err != nil {
+		log.Errorf("unable to register metrics: %s", err)
 	}
-	if err := res.Registry.Register(res.Deprecated); err != nil {
-		log.Errorf("unable to register deprecated metric: %s", err)
+	if err := prometheus.Register(res); err != nil {
+		log.Errorf("unable to register metrics in default registry: %s", err)
 	}
-	if err := res.Registry.Register(res.Replaced); err != nil {
-		log.Errorf("unable to register replaced metric: %s", err)
+	RegisterSink("prometheus", func(_ *SinkConfig) (Sink, error) {
+		return res, nil
+	})
+	return res
+}
+
+// SetReport - atomically replace exposed results of analyzed repository, results of last
+// successful analysis are kept when given one failed
+func (m *Metrics) SetReport(report *analysis.Report) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	state := &projectState{
+		result: ProjectResult{
+			Repository: report.Repository,
+			Ref:        report.Ref,
+			Status:     report.Success,
+			Time:       report.Time,
+		},
 	}
-	if err := res.Registry.Register(res.Status); err != nil {
-		log.Errorf("unable to register status metric: %s", err)
+	if report.Success {
+		state.result.Report = report
+	} else if previous, ok := m.projects[state.result.key()]; ok {
+		state.result.Report = previous.result.Report
+		state.restored = previous.restored
+	}
+	m.projects[state.result.key()] = state
+}
+
+// Restore - expose given persisted result until the repository is analyzed again
+func (m *Metrics) Restore(result ProjectResult) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.projects[result.key()] = &projectState{
+		result:   result,
+		restored: true,
 	}
-	if err := res.Registry.Register(res.Duration); err != nil {
-		log.Errorf("unable to register duration metric: %s", err)
+}
+
+// Result - current result of project with given key
+func (m *Metrics) Result(key string) (ProjectResult, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	state, ok := m.projects[key]
+	if !ok {
+		return ProjectResult{}, false
 	}
-	return res
+	return state.result, true
+}
+
+// Retain - drop all series of projects whose key is not in given list
+func (m *Metrics) Retain(keys []string) {
+	keep := map[string]bool{}
+	for _, cKey := range keys {
+		keep[cKey] = true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for cKey, cState := range m.projects {
+		if !keep[cKey] {
+			log.Infof("dropping metrics of unconfigured project %s", cState.result.key())
+			delete(m.projects, cKey)
+		}
+	}
+}
+
+// Describe - implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.info
+	ch <- m.deprecated
+	ch <- m.replaced
+	ch <- m.status
+	ch <- m.analyzed
+	ch <- m.incremental
+	ch <- m.deprecation
+	ch <- m.retracted
+	ch <- m.vulnerability
+	ch <- m.vulnerabilities
+	ch <- m.versionsBehind
+	ch <- m.versionLag
+	ch <- m.majorUpdate
+	ch <- m.versionAge
+	ch <- m.latestAge
+	ch <- m.releaseInterval
+	ch <- m.staleness
+	ch <- m.stalenessCount
+	ch <- m.expiredRules
+	ch <- m.policyCount
+	ch <- m.policyViolation
+	m.Duration.Describe(ch)
+}
+
+// Collect - implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.mutex.RLock()
+	keys := make([]string, 0, len(m.projects))
+	states := map[string]projectState{}
+	for cKey, cState := range m.projects {
+		keys = append(keys, cKey)
+		states[cKey] = *cState
+	}
+	m.mutex.RUnlock()
+	sort.Strings(keys)
+
+	// dependencies or projects resolving to the same labels would fail the whole scrape
+	series := &seriesWriter{ch: ch, seen: map[string]bool{}}
+	for _, cKey := range keys {
+		state := states[cKey]
+		repository, ref := utils.RedactURL(state.result.Repository), state.result.Ref
+		status := float64(0)
+		if state.result.Status {
+			status = 1
+		}
+		series.send(m.status, status, repository, ref)
+		if report := state.result.Report; report != nil {
+			series.send(
+				m.analyzed, float64(report.Time.Unix()),
+				repository, ref, strconv.FormatBool(state.restored),
+			)
+			incremental := float64(0)
+			if report.Incremental {
+				incremental = 1
+			}
+			series.send(m.incremental, incremental, repository, ref)
+			m.collectReport(series, repository, report)
+		}
+	}
+	m.Duration.Collect(ch)
+}
+
+// seriesWriter - sends gauges to a collector channel, only the first of several series with
+// same labels is kept
+type seriesWriter struct {
+	ch   chan<- prometheus.Metric
+	seen map[string]bool
+}
+
+func (w *seriesWriter) send(desc *prometheus.Desc, value float64, labels ...string) {
+	key := desc.String() + "\xff" + strings.Join(labels, "\xff")
+	if w.seen[key] {
+		return
+	}
+	w.seen[key] = true
+	w.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+func (m *Metrics) collectReport(series *seriesWriter, repository string, report *analysis.Report) {
+	series.send(m.info, 1, report.Module, repository, report.Ref, report.GoVersion)
+
+	for _, cDep := range report.Replaces {
+		series.send(
+			m.replaced, 1,
+			report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Replace.Path, cDep.Replace.Version,
+		)
+	}
+
+	vulnCount := 0
+	stateCount := map[string]int{}
+	now := time.Now()
+	for _, cDep := range report.Dependencies {
+		ignored := cDep.Ignored != nil && cDep.Ignored.Active(now)
+		if ignored && cDep.Ignored.Suppress {
+			continue
+		}
+		mValue, known := cDep.DaysBehind(now)
+		if !known {
+			mValue = unknownDaysBehind
+		}
+		mLatestVersion := cDep.Version
+		if cDep.Update != nil {
+			mLatestVersion = cDep.Update.Version
+		}
+		lag := cDep.Lag
+		if lag == nil {
+			lag = &analysis.VersionLag{Kind: analysis.UpdateNone}
+		}
+		series.send(
+			m.deprecated, mValue,
+			report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Version, mLatestVersion, lag.Kind,
+			strconv.FormatBool(cDep.Pseudo), strconv.FormatBool(cDep.Incompatible), strconv.FormatBool(ignored),
+		)
+		series.send(
+			m.versionsBehind, float64(lag.Behind),
+			report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), lag.Kind,
+		)
+		for component, value := range map[string]int{"major": lag.Major, "minor": lag.Minor, "patch": lag.Patch} {
+			series.send(
+				m.versionLag, float64(value),
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), lag.Kind, component,
+			)
+		}
+
+		if cDep.Time != nil {
+			series.send(
+				m.versionAge, daysSince(*cDep.Time),
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Version,
+			)
+		}
+		if cadence := cDep.Cadence; cadence != nil {
+			if cadence.Latest != nil && cadence.Latest.Time != nil {
+				series.send(
+					m.latestAge, daysSince(*cadence.Latest.Time),
+					report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cadence.Latest.Version,
+				)
+			}
+			if cadence.Releases != 0 {
+				series.send(
+					m.releaseInterval, cadence.Interval.Hours()/24.0,
+					report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), strconv.Itoa(cadence.Releases),
+				)
+			}
+		}
+
+		if next := cDep.NextMajor; next != nil {
+			timestamp := float64(0)
+			if next.Time != nil {
+				timestamp = float64(next.Time.Unix())
+			}
+			series.send(
+				m.majorUpdate, timestamp,
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), next.Path, next.Version, semver.Major(next.Version),
+			)
+		}
+
+		if cDep.Deprecated != "" {
+			series.send(
+				m.deprecation, 1,
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Deprecated, cDep.DeprecatedBy,
+			)
+		}
+		if len(cDep.Retracted) != 0 {
+			series.send(
+				m.retracted, 1,
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Version, strings.Join(cDep.Retracted, "; "),
+			)
+		}
+		for _, cVuln := range cDep.Vulns {
+			reachable := cVuln.Reachable
+			if reachable == "" {
+				reachable = "unknown"
+			}
+			series.send(
+				m.vulnerability, 1,
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Version, cVuln.ID, cVuln.Severity, cVuln.Fixed, reachable,
+			)
+		}
+		vulnCount += len(cDep.Vulns)
+
+		if cDep.Staleness != "" {
+			series.send(
+				m.staleness, 1,
+				report.Module, repository, report.Ref, cDep.Path, cDep.DependencyType(), cDep.Staleness,
+			)
+			stateCount[cDep.Staleness]++
+		}
+	}
+	series.send(m.expiredRules, float64(report.ExpiredIgnoreRules), report.Module, repository, report.Ref)
+	for _, cPolicy := range report.Policies {
+		series.send(m.policyCount, float64(len(cPolicy.Violations)), report.Module, repository, report.Ref, cPolicy.Name)
+		for _, cViolation := range cPolicy.Violations {
+			series.send(
+				m.policyViolation, 1,
+				report.Module, repository, report.Ref, cPolicy.Name, cViolation.Dependency, cViolation.Type, cViolation.Version,
+				strings.Join(cViolation.Reasons, "; "),
+			)
+		}
+	}
+	for _, cState := range analysis.StalenessStates {
+		series.send(m.stalenessCount, float64(stateCount[cState]), report.Module, repository, report.Ref, cState)
+	}
+	series.send(m.vulnerabilities, float64(vulnCount), report.Module, repository, report.Ref)
+}
+
+// unknownDaysBehind - days behind exported when release time of next version is unknown
+const unknownDaysBehind = 1000.0
+
+func daysSince(t time.Time) float64 {
+	return time.Since(t).Hours() / 24.0
 }
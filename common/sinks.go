@@ -0,0 +1,233 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Sink - destination receiving the report of each analyzed project
+type Sink interface {
+	Send(ctx context.Context, report *analysis.Report) error
+}
+
+// Retainer - sink keeping per project state, told which projects are still configured by
+// their GitConfig.Key
+type Retainer interface {
+	Retain(keys []string)
+}
+
+// SinkConfig - destination of analysis reports, fields used depend on Type
+type SinkConfig struct {
+	Type    string            `yaml:"type"`    // one of registered sink types: json, webhook, log...
+	Path    string            `yaml:"path"`    // json: directory receiving one report file per project, named after its url and ref
+	URL     string            `yaml:"url"`     // webhook: endpoint receiving reports as json POST requests
+	Headers map[string]string `yaml:"headers"` // webhook: additional request headers
+	Timeout string            `yaml:"timeout"` // webhook: request timeout, defaults to 30s
+
+	timeout time.Duration
+}
+
+// SinkFactory - build a sink from its validated configuration
+type SinkFactory func(config *SinkConfig) (Sink, error)
+
+var (
+	sinkMutex     sync.RWMutex
+	sinkFactories = map[string]SinkFactory{
+		"prometheus": newPrometheusSink,
+		"json":       newJSONSink,
+		"webhook":    newWebhookSink,
+		"log":        newLogSink,
+	}
+)
+
+// RegisterSink - make given sink type available in configuration, replacing any previous one
+func RegisterSink(name string, factory SinkFactory) {
+	sinkMutex.Lock()
+	defer sinkMutex.Unlock()
+	sinkFactories[name] = factory
+}
+
+// SinkTypes - names of registered sink types
+func SinkTypes() []string {
+	sinkMutex.RLock()
+	defer sinkMutex.RUnlock()
+	names := []string{}
+	for cName := range sinkFactories {
+		names = append(names, cName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *SinkConfig) validate() error {
+	sinkMutex.RLock()
+	_, ok := sinkFactories[c.Type]
+	sinkMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown sink type '%s', expected one of %v", c.Type, SinkTypes())
+	}
+	switch c.Type {
+	case "json":
+		if c.Path == "" {
+			return fmt.Errorf("json sink needs a path")
+		}
+		if err := os.MkdirAll(c.Path, 0o750); err != nil {
+			return fmt.Errorf("unable to create json sink directory '%s': %s", c.Path, err)
+		}
+	case "webhook":
+		target, err := url.Parse(c.URL)
+		if err != nil || target.Host == "" {
+			return fmt.Errorf("invalid webhook sink url '%s'", utils.RedactURL(c.URL))
+		}
+		if c.Timeout == "" {
+			c.Timeout = "30s"
+		}
+		if c.timeout, err = time.ParseDuration(c.Timeout); err != nil {
+			return fmt.Errorf("invalid webhook sink timeout '%s': %s", c.Timeout, err)
+		}
+	}
+	return nil
+}
+
+// NewSinks - build sinks of given validated configurations
+func NewSinks(configs []SinkConfig) ([]Sink, error) {
+	sinks := []Sink{}
+	for cIdx := range configs {
+		sinkMutex.RLock()
+		factory := sinkFactories[configs[cIdx].Type]
+		sinkMutex.RUnlock()
+		sink, err := factory(&configs[cIdx])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create %s sink", configs[cIdx].Type)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// newPrometheusSink - replaced by the collector of NewMetrics, which exposes reports as gauges
+func newPrometheusSink(_ *SinkConfig) (Sink, error) {
+	return nil, errors.New("prometheus metrics are not created")
+}
+
+// Send - implements Sink, expose report as prometheus gauges
+func (m *Metrics) Send(_ context.Context, report *analysis.Report) error {
+	m.SetReport(report)
+	return nil
+}
+
+// jsonSink - write report of each project to its own file of a directory
+type jsonSink struct {
+	dir string
+}
+
+func newJSONSink(config *SinkConfig) (Sink, error) {
+	return &jsonSink{dir: config.Path}, nil
+}
+
+func (s *jsonSink) Send(_ context.Context, report *analysis.Report) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to serialize report of %s", projectKey(report.Repository, report.Ref))
+	}
+	if err = utils.WriteFileAtomic(filepath.Join(s.dir, jsonSinkName(report)), content); err != nil {
+		return errors.Wrapf(err, "unable to write report of %s", projectKey(report.Repository, report.Ref))
+	}
+	return nil
+}
+
+// jsonSinkName - name of report file of project, such as
+// https_github.com_org_repo_main-0123abcd.json for ref main of https://github.com/org/repo
+func jsonSinkName(report *analysis.Report) string {
+	key := projectKey(report.Repository, report.Ref)
+	return utils.SafeName(key, key) + ".json"
+}
+
+// webhookSink - post report of each project as json to an http endpoint
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func newWebhookSink(config *SinkConfig) (Sink, error) {
+	return &webhookSink{
+		url:     config.URL,
+		headers: config.Headers,
+		timeout: config.timeout,
+		client:  &http.Client{},
+	}, nil
+}
+
+func (s *webhookSink) Send(ctx context.Context, report *analysis.Report) error {
+	content, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrapf(err, "unable to serialize report of %s", projectKey(report.Repository, report.Ref))
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(content))
+	if err != nil {
+		return errors.Wrap(err, "unable to build webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for cName, cValue := range s.headers {
+		req.Header.Set(cName, cValue)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to call webhook")
+	}
+	defer utils.CloseAndLogError(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %d from webhook", resp.StatusCode)
+	}
+	return nil
+}
+
+// logSink - log a one line summary of each report
+type logSink struct{}
+
+func newLogSink(_ *SinkConfig) (Sink, error) {
+	return logSink{}, nil
+}
+
+func (logSink) Send(_ context.Context, report *analysis.Report) error {
+	entry := log.WithFields(log.Fields{
+		"url":    utils.RedactURL(report.Repository),
+		"ref":    report.Ref,
+		"commit": report.Commit,
+	})
+	if !report.Success {
+		entry.Warnf("analysis failed: %v", report.Errors)
+		return nil
+	}
+	outdated, vulns, violations := 0, 0, 0
+	for _, cDep := range report.Dependencies {
+		if cDep.Update != nil {
+			outdated++
+		}
+		vulns += len(cDep.Vulns)
+	}
+	for _, cPolicy := range report.Policies {
+		violations += len(cPolicy.Violations)
+	}
+	entry.Infof("analyzed %s: %d dependencies, %d outdated, %d vulnerabilities, %d policy violations",
+		report.Module, len(report.Dependencies), outdated, vulns, violations)
+	return nil
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
+	"github.com/pkg/errors"
+)
+
+// StateConfig - local persistence of analysis results
+type StateConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+func (c *StateConfig) validate() error {
+	if c.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o750); err != nil {
+		return fmt.Errorf("unable to create state directory '%s': %s", c.Dir, err)
+	}
+	return nil
+}
+
+// ProjectResult - last known analysis outcome of a project
+type ProjectResult struct {
+	Repository string
+	Ref        string           `json:",omitempty"` // analyzed ref, empty for default branch
+	Status     bool             // last analysis succeeded
+	Time       time.Time        // time of last analysis attempt
+	Report     *analysis.Report `json:",omitempty"` // report of last successful analysis
+}
+
+// StateStore - persist ProjectResult of each project as json files in a directory
+type StateStore struct {
+	dir string
+}
+
+// NewStateStore - create StateStore from given configuration, nil when persistence is disabled
+func NewStateStore(config *StateConfig) *StateStore {
+	if config.Dir == "" {
+		return nil
+	}
+	return &StateStore{dir: config.Dir}
+}
+
+func (r *ProjectResult) key() string {
+	return projectKey(r.Repository, r.Ref)
+}
+
+func (s *StateStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Save - write result of given project, replacing previous file atomically
+func (s *StateStore) Save(result *ProjectResult) error {
+	content, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrapf(err, "unable to serialize state of %s", result.key())
+	}
+	if err = utils.WriteFileAtomic(s.path(result.key()), content); err != nil {
+		return errors.Wrap(err, "unable to write state file")
+	}
+	return nil
+}
+
+// Load - read all persisted results
+func (s *StateStore) Load() ([]ProjectResult, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read state directory '%s'", s.dir)
+	}
+	results := []ProjectResult{}
+	for _, cEntry := range entries {
+		if cEntry.IsDir() || !strings.HasSuffix(cEntry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, cEntry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read state file '%s'", path)
+		}
+		result := ProjectResult{}
+		if err = json.Unmarshal(content, &result); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse state file '%s'", path)
+		}
+		// results persisted with credentials of their repository are rewritten without them
+		result.Repository = utils.RedactURL(result.Repository)
+		if result.Report != nil {
+			result.Report.Repository = utils.RedactURL(result.Report.Repository)
+		}
+		if s.path(result.key()) != path {
+			if err = s.Save(&result); err != nil {
+				return nil, err
+			}
+			if err = os.Remove(path); err != nil {
+				return nil, errors.Wrapf(err, "unable to remove state file '%s'", path)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Remove - delete persisted result of project with given key
+func (s *StateStore) Remove(key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "unable to remove state file '%s'", s.path(key))
+	}
+	return nil
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/gorilla/mux"
 	"github.com/orange-cloudfoundry/gomod_exporter/common"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
 	log "github.com/sirupsen/logrus"
-	"net/http"
 )
 
 var (
@@ -22,16 +28,40 @@ func main() {
 	config := NewConfig(*configFile)
 	common.InitLogs(&config.BaseConfig)
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	metrics := common.NewMetrics(config.Exporter.Namespace)
 	analyzer := common.NewAnalyzer(&config.BaseConfig, metrics)
-	analyzer.RunForever(config.Exporter.intervalDuration)
+	analyzer.Restore()
+	analyzerDone := analyzer.RunForever(ctx, config.Exporter.intervalDuration)
 
 	router := mux.NewRouter()
 	router.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:    config.Web.Listen,
+		Handler: router,
+	}
+	go func() {
+		<-ctx.Done()
+		log.Infof("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("unable to shutdown http server: %s", err)
+		}
+	}()
+
+	var err error
 	if (config.Web.SSLCertPath != "") && (config.Web.SSLKeyPath != "") {
 		log.Infof("serving https on %s", config.Web.Listen)
-		panic(http.ListenAndServeTLS(config.Web.Listen, config.Web.SSLCertPath, config.Web.SSLKeyPath, router))
+		err = server.ListenAndServeTLS(config.Web.SSLCertPath, config.Web.SSLKeyPath)
+	} else {
+		log.Infof("serving http on %s", config.Web.Listen)
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
 	}
-	log.Infof("serving http on %s", config.Web.Listen)
-	panic(http.ListenAndServe(config.Web.Listen, router))
+	<-analyzerDone
 }
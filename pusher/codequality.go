@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// issue of GitLab code quality report, see https://docs.gitlab.com/ci/testing/code_quality/
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// writeCodeQuality - write findings as a GitLab code quality report
+func writeCodeQuality(out io.Writer, goMod string, findings []finding) error {
+	issues := []codeQualityIssue{}
+	for _, cFinding := range findings {
+		issues = append(issues, codeQualityIssue{
+			Description: cFinding.message,
+			CheckName:   cFinding.rule.id,
+			Fingerprint: cFinding.fingerprint(),
+			Severity:    cFinding.severity,
+			Location: codeQualityLocation{
+				Path:  goMod,
+				Lines: codeQualityLines{Begin: cFinding.line},
+			},
+		})
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
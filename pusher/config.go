@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/orange-cloudfoundry/gomod_exporter/common"
+	log "github.com/sirupsen/logrus"
 )
 
 // Config -
@@ -11,6 +15,10 @@ type Config struct {
 	gwSkipSSL     bool
 	metricJobName string
 	metricNS      string
+	noPush        bool
+	outputFormat  string
+	outputFile    string
+	gate          GateConfig
 }
 
 // Validate - Validate configuration object
@@ -18,6 +26,12 @@ func (c *Config) Validate() error {
 	if err := c.BaseConfig.Validate(); err != nil {
 		return err
 	}
+	if c.gwURL == "" && !c.noPush {
+		return fmt.Errorf("pushgw-url is required unless metrics are not pushed")
+	}
+	if err := c.gate.validate(); err != nil {
+		return fmt.Errorf("invalid ci gate: %s", err)
+	}
 	return nil
 }
 
@@ -25,12 +39,25 @@ func (c *Config) Validate() error {
 func NewConfig() *Config {
 	project := common.GitConfig{
 		URL: *projectURL,
+		Ref: *projectRef,
 		Dir: *projectDir,
 	}
 	if projectUsername != nil && projectPassword != nil {
 		project.Auth = &common.GitAuth{
-			Username: *projectUsername,
-			Password: *projectPassword,
+			Username:         *projectUsername,
+			Password:         *projectPassword,
+			PasswordFile:     *projectPassFile,
+			Token:            *projectToken,
+			TokenFile:        *projectTokenFile,
+			Netrc:            *projectNetrc,
+			CredentialHelper: *projectCredHelper,
+		}
+	}
+	if *projectSSHKey != "" || len(*projectKnownHosts) != 0 {
+		project.Auth.SSH = &common.SSHAuth{
+			KeyFile:    *projectSSHKey,
+			Passphrase: *projectSSHPass,
+			KnownHosts: *projectKnownHosts,
 		}
 	}
 
@@ -39,9 +66,30 @@ func NewConfig() *Config {
 		gwSkipSSL:     *pushGwSkipSSL,
 		metricJobName: *metricJobName,
 		metricNS:      *metricNS,
+		noPush:        *noPush || *fake,
+		outputFormat:  *outputFormat,
+		outputFile:    *outputFile,
+		gate: GateConfig{
+			Enabled:           *ciGate,
+			MaxDaysBehind:     *ciMaxDaysBehind,
+			MaxOutdatedDirect: *ciMaxOutdated,
+			MaxMajorLag:       *ciMaxMajorLag,
+			Forbidden:         *ciForbidden,
+		},
+	}
+
+	sinkConfigs := []common.SinkConfig{}
+	for _, cSink := range *sinks {
+		sink := common.SinkConfig{}
+		sink.Type, sink.Path, _ = strings.Cut(cSink, ":")
+		if sink.Type == "webhook" {
+			sink.URL, sink.Path = sink.Path, ""
+		}
+		sinkConfigs = append(sinkConfigs, sink)
 	}
 
 	config.BaseConfig = common.BaseConfig{
+		Sinks:    sinkConfigs,
 		Projects: []common.GitConfig{project},
 		Log: common.LogConfig{
 			JSON:    *logJSON,
@@ -50,5 +98,8 @@ func NewConfig() *Config {
 		},
 	}
 
+	if err := config.Validate(); err != nil {
+		log.Fatalf("invalid configuration, %s", err)
+	}
 	return config
 }
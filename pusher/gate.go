@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+)
+
+// exit codes of pusher, gate failures are sorted by code so that the lowest one is returned
+const (
+	exitOK             = 0
+	exitAnalysisError  = 1
+	exitDaysBehind     = 10
+	exitOutdatedDirect = 11
+	exitForbidden      = 12
+	exitMajorLag       = 13
+)
+
+// GateConfig - thresholds failing the pusher in CI mode, negative values disable a check
+type GateConfig struct {
+	Enabled           bool
+	MaxDaysBehind     int
+	MaxOutdatedDirect int
+	MaxMajorLag       int
+	Forbidden         []string
+}
+
+func (c *GateConfig) validate() error {
+	for _, cPattern := range c.Forbidden {
+		if _, err := path.Match(cPattern, ""); err != nil {
+			return fmt.Errorf("invalid forbidden module pattern '%s': %s", cPattern, err)
+		}
+	}
+	return nil
+}
+
+type gateFailure struct {
+	code    int
+	message string
+}
+
+// evaluateGate - thresholds broken by dependencies of given analysis, ignored dependencies excluded
+func evaluateGate(config *GateConfig, report *analysis.Report, at time.Time) []gateFailure {
+	failures := []gateFailure{}
+	outdatedDirect := 0
+	for _, cDep := range report.Dependencies {
+		if cDep.Ignored != nil && cDep.Ignored.Active(at) {
+			continue
+		}
+		if cDep.Update != nil && !cDep.Indirect {
+			outdatedDirect++
+		}
+		// lag of dependencies with unknown release times can not be checked
+		if config.MaxDaysBehind >= 0 {
+			if days, known := cDep.DaysBehind(at); known && days > float64(config.MaxDaysBehind) {
+				failures = append(failures, gateFailure{
+					code:    exitDaysBehind,
+					message: fmt.Sprintf("%s@%s is %.0f days behind (max %d)", cDep.Path, cDep.Version, days, config.MaxDaysBehind),
+				})
+			}
+		}
+		if config.MaxMajorLag >= 0 {
+			if majors := cDep.MajorsBehind(); majors > config.MaxMajorLag {
+				failures = append(failures, gateFailure{
+					code:    exitMajorLag,
+					message: fmt.Sprintf("%s@%s is %d major versions behind (max %d)", cDep.Path, cDep.Version, majors, config.MaxMajorLag),
+				})
+			}
+		}
+		for _, cPattern := range config.Forbidden {
+			if ok, _ := path.Match(cPattern, cDep.Path); ok {
+				failures = append(failures, gateFailure{
+					code:    exitForbidden,
+					message: fmt.Sprintf("%s@%s is forbidden by pattern %s", cDep.Path, cDep.Version, cPattern),
+				})
+				break
+			}
+		}
+	}
+	if config.MaxOutdatedDirect >= 0 && outdatedDirect > config.MaxOutdatedDirect {
+		failures = append(failures, gateFailure{
+			code:    exitOutdatedDirect,
+			message: fmt.Sprintf("%d direct dependencies are outdated (max %d)", outdatedDirect, config.MaxOutdatedDirect),
+		})
+	}
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].code < failures[j].code
+	})
+	return failures
+}
+
+// writeGateSummary - human readable outcome of gate evaluation
+func writeGateSummary(out io.Writer, report *analysis.Report, failures []gateFailure) {
+	if len(failures) == 0 {
+		_, _ = fmt.Fprintf(out, "dependency gate passed for %s (%d dependencies)\n", report.Module, len(report.Dependencies))
+		return
+	}
+	_, _ = fmt.Fprintf(out, "dependency gate failed for %s, %d problems found:\n", report.Module, len(failures))
+	for _, cFailure := range failures {
+		_, _ = fmt.Fprintf(out, "  [%d] %s\n", cFailure.code, cFailure.message)
+	}
+}
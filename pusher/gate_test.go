@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+)
+
+func TestEvaluateGate(t *testing.T) {
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	released := at.AddDate(0, 0, -200)
+	nextReleased := at.AddDate(0, 0, -100)
+	until := at.AddDate(0, 0, 1)
+
+	// direct dependency 100 days behind
+	behind := analysis.ModulePublic{
+		Path: "github.com/foo/behind", Version: "v1.0.0", Time: &released,
+		Update:     &analysis.ModulePublic{Version: "v1.2.0"},
+		NextUpdate: &analysis.ModulePublic{Version: "v1.1.0", Time: &nextReleased},
+	}
+	// up-to-date dependency two majors behind its newest module path
+	major := analysis.ModulePublic{
+		Path: "github.com/foo/major", Version: "v1.4.0", Lag: &analysis.VersionLag{},
+		NextMajor: &analysis.ModulePublic{Path: "github.com/foo/major/v3", Version: "v3.0.0"},
+	}
+	forbidden := analysis.ModulePublic{Path: "github.com/bad/module", Version: "v0.1.0", Indirect: true}
+	// outdated dependency whose next version has no known release time
+	unknown := behind
+	unknown.Path = "github.com/foo/unknown"
+	unknown.NextUpdate = &analysis.ModulePublic{Version: "v1.1.0"}
+	ignored := behind
+	ignored.Path = "github.com/foo/ignored"
+	ignored.Ignored = &analysis.IgnoreMatch{Until: &until}
+
+	permissive := GateConfig{MaxDaysBehind: -1, MaxOutdatedDirect: -1, MaxMajorLag: -1}
+	tests := []struct {
+		name   string
+		config GateConfig
+		deps   []analysis.ModulePublic
+		codes  []int
+	}{
+		{
+			name:   "all checks disabled",
+			config: permissive,
+			deps:   []analysis.ModulePublic{behind, major, forbidden},
+			codes:  []int{},
+		},
+		{
+			name:   "days behind",
+			config: GateConfig{MaxDaysBehind: 90, MaxOutdatedDirect: -1, MaxMajorLag: -1},
+			deps:   []analysis.ModulePublic{behind, major},
+			codes:  []int{exitDaysBehind},
+		},
+		{
+			name:   "days behind within threshold",
+			config: GateConfig{MaxDaysBehind: 100, MaxOutdatedDirect: -1, MaxMajorLag: -1},
+			deps:   []analysis.ModulePublic{behind},
+			codes:  []int{},
+		},
+		{
+			name:   "days behind unknown",
+			config: GateConfig{MaxDaysBehind: 0, MaxOutdatedDirect: -1, MaxMajorLag: -1},
+			deps:   []analysis.ModulePublic{unknown},
+			codes:  []int{},
+		},
+		{
+			name:   "outdated direct dependencies",
+			config: GateConfig{MaxDaysBehind: -1, MaxOutdatedDirect: 0, MaxMajorLag: -1},
+			deps:   []analysis.ModulePublic{behind, forbidden},
+			codes:  []int{exitOutdatedDirect},
+		},
+		{
+			name:   "forbidden module",
+			config: GateConfig{MaxDaysBehind: -1, MaxOutdatedDirect: -1, MaxMajorLag: -1, Forbidden: []string{"github.com/bad/*", "github.com/*/module"}},
+			deps:   []analysis.ModulePublic{behind, forbidden},
+			codes:  []int{exitForbidden},
+		},
+		{
+			name:   "major lag",
+			config: GateConfig{MaxDaysBehind: -1, MaxOutdatedDirect: -1, MaxMajorLag: 1},
+			deps:   []analysis.ModulePublic{behind, major},
+			codes:  []int{exitMajorLag},
+		},
+		{
+			name:   "failures sorted by precedence",
+			config: GateConfig{MaxDaysBehind: 0, MaxOutdatedDirect: 0, MaxMajorLag: 0, Forbidden: []string{"github.com/bad/*"}},
+			deps:   []analysis.ModulePublic{major, forbidden, behind},
+			codes:  []int{exitDaysBehind, exitOutdatedDirect, exitForbidden, exitMajorLag},
+		},
+		{
+			name:   "ignored dependencies are skipped",
+			config: GateConfig{MaxDaysBehind: 0, MaxOutdatedDirect: 0, MaxMajorLag: 0},
+			deps:   []analysis.ModulePublic{ignored},
+			codes:  []int{},
+		},
+	}
+	for _, cTest := range tests {
+		t.Run(cTest.name, func(t *testing.T) {
+			failures := evaluateGate(&cTest.config, &analysis.Report{Dependencies: cTest.deps}, at)
+			codes := []int{}
+			for _, cFailure := range failures {
+				codes = append(codes, cFailure.code)
+			}
+			if !reflect.DeepEqual(codes, cTest.codes) {
+				t.Errorf("expected codes %v, got %v", cTest.codes, codes)
+			}
+		})
+	}
+}
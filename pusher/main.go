@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
 	"github.com/orange-cloudfoundry/gomod_exporter/common"
+	"github.com/orange-cloudfoundry/gomod_exporter/utils"
 	"github.com/prometheus/client_golang/prometheus/push"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/version"
@@ -14,18 +23,36 @@ import (
 )
 
 var (
-	pushGwURL       = kingpin.Flag("pushgw-url", "Push gateway url").Required().String()
-	pushGwSkipSSL   = kingpin.Flag("pushgw-unsecure", "Skip SSL verify").Bool()
-	metricJobName   = kingpin.Flag("metric-job-name", "name seen by prometheus as job_name").Default("gomod").String()
-	metricNS        = kingpin.Flag("metric-namespace", "metric prefix namespace").Default("gomod").String()
-	projectURL      = kingpin.Flag("project-url", "Git target project to analyze").Required().String()
-	projectUsername = kingpin.Flag("project-user", "(optional) username for git authentication").String()
-	projectPassword = kingpin.Flag("project-password", "(optional) password for git authentication").String()
-	projectDir      = kingpin.Flag("project-dir", "(optional) use given directory instead of cloning project").String()
-	fake            = kingpin.Flag("fake", "(optional) do not push metrics, only prints on stdout").Bool()
-	logLevel        = kingpin.Flag("log-level", "Log level").Default("info").String()
-	logJSON         = kingpin.Flag("log-json", "Log in JSON").Bool()
-	logNoColor      = kingpin.Flag("log-no-color", "Disable log coloring").Bool()
+	pushGwURL         = kingpin.Flag("pushgw-url", "Push gateway url, required unless --fake or --no-push is given").String()
+	pushGwSkipSSL     = kingpin.Flag("pushgw-unsecure", "Skip SSL verify").Bool()
+	metricJobName     = kingpin.Flag("metric-job-name", "name seen by prometheus as job_name").Default("gomod").String()
+	metricNS          = kingpin.Flag("metric-namespace", "metric prefix namespace").Default("gomod").String()
+	projectURL        = kingpin.Flag("project-url", "Git target project to analyze").Required().String()
+	projectUsername   = kingpin.Flag("project-user", "(optional) username for git authentication").String()
+	projectPassword   = kingpin.Flag("project-password", "(optional) password for git authentication, visible in process list, prefer GOMOD_PROJECT_PASSWORD or --project-password-file").Envar("GOMOD_PROJECT_PASSWORD").String()
+	projectPassFile   = kingpin.Flag("project-password-file", "(optional) file holding password for git authentication").String()
+	projectToken      = kingpin.Flag("project-token", "(optional) bearer token for git authentication, visible in process list, prefer GOMOD_PROJECT_TOKEN or --project-token-file").Envar("GOMOD_PROJECT_TOKEN").String()
+	projectTokenFile  = kingpin.Flag("project-token-file", "(optional) file holding bearer token for git authentication").String()
+	projectNetrc      = kingpin.Flag("project-netrc", "(optional) look git credentials up in NETRC or ~/.netrc").Bool()
+	projectCredHelper = kingpin.Flag("project-credential-helper", "(optional) ask git credentials to configured git credential helpers").Bool()
+	projectSSHKey     = kingpin.Flag("project-ssh-key", "(optional) private key file for ssh git authentication, ssh-agent is used otherwise").String()
+	projectSSHPass    = kingpin.Flag("project-ssh-passphrase", "(optional) passphrase of ssh private key").Envar("GOMOD_PROJECT_SSH_PASSPHRASE").String()
+	projectKnownHosts = kingpin.Flag("project-known-hosts", "(optional) known_hosts file checked against ssh host key, may be repeated").Strings()
+	projectRef        = kingpin.Flag("project-ref", "(optional) branch, tag, full commit hash or semver tag glob such as v1.* to analyze instead of default branch").String()
+	projectDir        = kingpin.Flag("project-dir", "(optional) use given directory instead of cloning project").String()
+	fake              = kingpin.Flag("fake", "(optional) do not push metrics, only prints on stdout").Bool()
+	outputFormat      = kingpin.Flag("output-format", "(optional) format of report written on output, one of prometheus, json, sarif, codequality, table or markdown").Default(formatPrometheus).Enum(outputFormats...)
+	outputFile        = kingpin.Flag("output-file", "(optional) write report to given file instead of stdout").String()
+	sinks             = kingpin.Flag("sink", "(optional) additional report destination as type:target, such as json:<dir>, webhook:<url> or log, may be repeated").Strings()
+	noPush            = kingpin.Flag("no-push", "(optional) do not push metrics to gateway").Bool()
+	ciGate            = kingpin.Flag("ci", "(optional) fail with a dedicated exit code when dependencies break given thresholds").Bool()
+	ciMaxDaysBehind   = kingpin.Flag("ci-max-days-behind", "(optional) max days a dependency may lag behind its latest version, -1 to disable").Default("-1").Int()
+	ciMaxOutdated     = kingpin.Flag("ci-max-outdated-direct", "(optional) max number of outdated direct dependencies, -1 to disable").Default("-1").Int()
+	ciMaxMajorLag     = kingpin.Flag("ci-max-major-lag", "(optional) max major versions a dependency may lag behind, -1 to disable").Default("-1").Int()
+	ciForbidden       = kingpin.Flag("ci-forbid", "(optional) glob of forbidden dependency paths, may be repeated").Strings()
+	logLevel          = kingpin.Flag("log-level", "Log level").Default("info").String()
+	logJSON           = kingpin.Flag("log-json", "Log in JSON").Bool()
+	logNoColor        = kingpin.Flag("log-no-color", "Disable log coloring").Bool()
 )
 
 func main() {
@@ -36,43 +63,87 @@ func main() {
 	config := NewConfig()
 	common.InitLogs(&config.BaseConfig)
 
-	var exitCode = 0
+	var exitCode = exitOK
 
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: config.gwSkipSSL,
 	}
 	metrics := common.NewMetrics(config.metricNS)
 	analyzer := common.NewAnalyzer(&config.BaseConfig, metrics)
-	pusher := push.New(config.gwURL, config.metricJobName)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	project := config.Projects[0]
-	if err := analyzer.ProcessProject(&project); err != nil {
+	report, err := analyzer.ProcessProject(ctx, &project)
+	if err != nil {
 		log.Warnf("unable to analyze project: %s", err)
 		log.Warnf("failure will be reported in pushed metrics")
-		exitCode = 1
+		exitCode = exitAnalysisError
 	}
 
-	if *fake {
-		gathering, err := metrics.Registry.Gather()
-		if err != nil {
-			log.Errorf("unable to gather metrics: %s", err)
-			os.Exit(1)
+	if config.gate.Enabled && exitCode == exitOK {
+		failures := evaluateGate(&config.gate, report, time.Now())
+		writeGateSummary(os.Stderr, report, failures)
+		if len(failures) != 0 {
+			exitCode = failures[0].code
 		}
-		for _, cMF := range gathering {
-			_, err = expfmt.MetricFamilyToText(os.Stdout, cMF)
-			if err != nil {
-				log.Errorf("unable to output metric: %s", err)
-				os.Exit(1)
-			}
+	}
+
+	if *fake || config.outputFormat != formatPrometheus {
+		if err := writeOutput(config, metrics, report); err != nil {
+			log.Errorf("unable to write report: %s", err)
+			os.Exit(exitAnalysisError)
 		}
+	}
+
+	if config.noPush {
 		os.Exit(exitCode)
 	}
 
+	pusher := push.New(config.gwURL, config.metricJobName)
 	pusher.Gatherer(metrics.Registry)
-	pusher.Grouping("project", project.URL)
+	pusher.Grouping("project", utils.RedactURL(project.URL))
+	if project.Ref != "" {
+		pusher.Grouping("ref", project.Ref)
+	}
 	if err := pusher.Add(); err != nil {
 		log.Errorf("unable to push data to gateway: %s", err)
-		os.Exit(1)
+		os.Exit(exitAnalysisError)
 	}
 	os.Exit(exitCode)
 }
+
+// writeOutput - write metrics or report of analyzed project in configured format
+func writeOutput(config *Config, metrics *common.Metrics, report *analysis.Report) error {
+	out := io.Writer(os.Stdout)
+	if config.outputFile != "" {
+		file, err := os.Create(config.outputFile)
+		if err != nil {
+			return err
+		}
+		defer utils.CloseAndLogError(file)
+		out = file
+	}
+
+	if config.outputFormat == formatJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if config.outputFormat != formatPrometheus {
+		return writeReport(out, config.outputFormat, report, time.Now())
+	}
+
+	gathering, err := metrics.Registry.Gather()
+	if err != nil {
+		return fmt.Errorf("unable to gather metrics: %s", err)
+	}
+	for _, cMF := range gathering {
+		if _, err = expfmt.MetricFamilyToText(out, cMF); err != nil {
+			return fmt.Errorf("unable to output metric: %s", err)
+		}
+	}
+	return nil
+}
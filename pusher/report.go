@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+)
+
+// Output formats of pusher
+const (
+	formatPrometheus  = "prometheus"
+	formatJSON        = "json"
+	formatSARIF       = "sarif"
+	formatCodeQuality = "codequality"
+	formatTable       = "table"
+	formatMarkdown    = "markdown"
+)
+
+var outputFormats = []string{formatPrometheus, formatJSON, formatSARIF, formatCodeQuality, formatTable, formatMarkdown}
+
+// Severities of findings, from GitLab code quality scale
+const (
+	severityInfo     = "info"
+	severityMinor    = "minor"
+	severityMajor    = "major"
+	severityCritical = "critical"
+	severityBlocker  = "blocker"
+)
+
+// findingRule - kind of problem reported on a dependency
+type findingRule struct {
+	id          string
+	description string
+}
+
+var (
+	ruleOutdated   = findingRule{"outdated", "Dependency has a newer version"}
+	ruleDeprecated = findingRule{"deprecated", "Dependency is deprecated"}
+	ruleRetracted  = findingRule{"retracted", "Dependency version is retracted"}
+	ruleVulnerable = findingRule{"vulnerable", "Dependency version is affected by a known vulnerability"}
+	ruleAbandoned  = findingRule{"abandoned", "Dependency is no longer released"}
+	rulePolicy     = findingRule{"policy", "Dependency breaks a policy rule"}
+	findingRules   = []findingRule{ruleOutdated, ruleDeprecated, ruleRetracted, ruleVulnerable, ruleAbandoned, rulePolicy}
+)
+
+// finding - problem found on a dependency, anchored to its require directive
+type finding struct {
+	rule       findingRule
+	severity   string
+	dependency string
+	version    string
+	line       int
+	message    string
+	// distinguishes findings of the same rule on the same dependency
+	detail string
+}
+
+// fingerprint - stable identifier of the finding across analyses of the same project
+func (f *finding) fingerprint() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.rule.id, f.dependency, f.version, f.detail}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// updateSeverity - severity of a pending update, from its semantic distance
+func updateSeverity(module *analysis.ModulePublic) string {
+	if module.MajorsBehind() != 0 {
+		return severityMajor
+	}
+	if module.Lag != nil && module.Lag.Kind == analysis.UpdateMinor {
+		return severityMinor
+	}
+	return severityInfo
+}
+
+// findingLine - given line of require directive, beginning of go.mod for dependencies not required
+// explicitly
+func findingLine(line int) int {
+	if line == 0 {
+		return 1
+	}
+	return line
+}
+
+// collectFindings - problems of all dependencies of given analysis not muted by an ignore rule
+func collectFindings(report *analysis.Report, at time.Time) []finding {
+	findings := []finding{}
+	byPath := map[string]*analysis.ModulePublic{}
+	for cIdx := range report.Dependencies {
+		dep := &report.Dependencies[cIdx]
+		byPath[dep.Path] = dep
+		if dep.Ignored != nil && dep.Ignored.Active(at) {
+			continue
+		}
+		base := finding{dependency: dep.Path, version: dep.Version, line: findingLine(dep.Line)}
+
+		if dep.Update != nil {
+			f := base
+			f.rule = ruleOutdated
+			f.severity = updateSeverity(dep)
+			f.message = fmt.Sprintf("%s@%s can be updated to %s", dep.Path, dep.Version, dep.Update.Version)
+			findings = append(findings, f)
+		}
+		if dep.NextMajor != nil {
+			f := base
+			f.rule = ruleOutdated
+			f.severity = severityMajor
+			f.message = fmt.Sprintf("%s@%s has a newer major version %s@%s", dep.Path, dep.Version, dep.NextMajor.Path, dep.NextMajor.Version)
+			f.detail = dep.NextMajor.Path
+			findings = append(findings, f)
+		}
+		if dep.Deprecated != "" {
+			f := base
+			f.rule = ruleDeprecated
+			f.severity = severityMajor
+			f.message = fmt.Sprintf("%s is deprecated: %s", dep.Path, dep.Deprecated)
+			findings = append(findings, f)
+		}
+		if len(dep.Retracted) != 0 {
+			f := base
+			f.rule = ruleRetracted
+			f.severity = severityCritical
+			f.message = fmt.Sprintf("%s@%s is retracted: %s", dep.Path, dep.Version, strings.Join(dep.Retracted, "; "))
+			findings = append(findings, f)
+		}
+		for _, cVuln := range dep.Vulns {
+			f := base
+			f.rule = ruleVulnerable
+			f.severity = severityCritical
+			if cVuln.Reachable == analysis.ReachableYes {
+				f.severity = severityBlocker
+			}
+			f.message = fmt.Sprintf("%s@%s is affected by %s: %s", dep.Path, dep.Version, cVuln.ID, cVuln.Summary)
+			if cVuln.Fixed != "" {
+				f.message += fmt.Sprintf(" (fixed in %s)", cVuln.Fixed)
+			}
+			f.detail = cVuln.ID
+			findings = append(findings, f)
+		}
+		if dep.Staleness == analysis.StalenessAbandoned {
+			f := base
+			f.rule = ruleAbandoned
+			f.severity = severityMinor
+			f.message = fmt.Sprintf("%s is abandoned, no release for a long time", dep.Path)
+			findings = append(findings, f)
+		}
+	}
+
+	// ignored dependencies are already excluded from policy evaluation
+	for _, cPolicy := range report.Policies {
+		for _, cViolation := range cPolicy.Violations {
+			f := finding{
+				rule:       rulePolicy,
+				severity:   severityMajor,
+				dependency: cViolation.Dependency,
+				version:    cViolation.Version,
+				message:    fmt.Sprintf("%s@%s breaks policy %s: %s", cViolation.Dependency, cViolation.Version, cPolicy.Name, strings.Join(cViolation.Reasons, ", ")),
+				detail:     cPolicy.Name,
+				line:       findingLine(0),
+			}
+			if dep, ok := byPath[cViolation.Dependency]; ok {
+				f.line = findingLine(dep.Line)
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// writeReport - write findings of given analysis in given format
+func writeReport(out io.Writer, format string, report *analysis.Report, at time.Time) error {
+	switch format {
+	case formatTable:
+		return writeTable(out, report, at)
+	case formatMarkdown:
+		return writeMarkdown(out, report, at)
+	}
+
+	findings := collectFindings(report, at)
+	goMod := "go.mod"
+	if report.GoMod != "" {
+		goMod = report.GoMod
+	}
+	switch format {
+	case formatSARIF:
+		return writeSARIF(out, goMod, findings)
+	case formatCodeQuality:
+		return writeCodeQuality(out, goMod, findings)
+	}
+	return fmt.Errorf("unsupported report format '%s'", format)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/prometheus/common/version"
+)
+
+// minimal subset of SARIF 2.1.0 format, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevel - SARIF level matching given finding severity
+func sarifLevel(severity string) string {
+	switch severity {
+	case severityBlocker, severityCritical:
+		return "error"
+	case severityMajor, severityMinor:
+		return "warning"
+	}
+	return "note"
+}
+
+// writeSARIF - write findings as a SARIF log with a single run
+func writeSARIF(out io.Writer, goMod string, findings []finding) error {
+	driver := sarifDriver{
+		Name:           "gomod_exporter",
+		Version:        version.Version,
+		InformationURI: "https://github.com/orange-cloudfoundry/gomod_exporter",
+		Rules:          []sarifRule{},
+	}
+	for _, cRule := range findingRules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               cRule.id,
+			ShortDescription: sarifMessage{Text: cRule.description},
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, cFinding := range findings {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  cFinding.rule.id,
+			Level:   sarifLevel(cFinding.severity),
+			Message: sarifMessage{Text: cFinding.message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: goMod},
+					Region:           sarifRegion{StartLine: cFinding.line},
+				},
+			}},
+			PartialFingerprints: map[string]string{"gomodFinding/v1": cFinding.fingerprint()},
+		})
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/orange-cloudfoundry/gomod_exporter/analysis"
+)
+
+var tableHeader = []string{"DEPENDENCY", "TYPE", "CURRENT", "LATEST", "DAYS BEHIND", "UPDATE"}
+
+// tableRows - one row per dependency, most outdated direct dependencies first
+func tableRows(report *analysis.Report, at time.Time) [][]string {
+	deps := []*analysis.ModulePublic{}
+	for cIdx := range report.Dependencies {
+		dep := &report.Dependencies[cIdx]
+		if dep.Ignored != nil && dep.Ignored.Suppress && dep.Ignored.Active(at) {
+			continue
+		}
+		deps = append(deps, dep)
+	}
+	sort.SliceStable(deps, func(i, j int) bool {
+		if deps[i].Indirect != deps[j].Indirect {
+			return !deps[i].Indirect
+		}
+		// dependencies with unknown release times first, they are outdated too
+		di, iKnown := deps[i].DaysBehind(at)
+		dj, jKnown := deps[j].DaysBehind(at)
+		if iKnown != jKnown {
+			return !iKnown
+		}
+		if di != dj {
+			return di > dj
+		}
+		return deps[i].Path < deps[j].Path
+	})
+
+	rows := [][]string{}
+	for _, cDep := range deps {
+		latest := cDep.Version
+		if cDep.Update != nil {
+			latest = cDep.Update.Version
+		}
+		kind := analysis.UpdateNone
+		if cDep.Lag != nil {
+			kind = cDep.Lag.Kind
+		}
+		if cDep.Ignored != nil && cDep.Ignored.Active(at) {
+			kind += " (ignored)"
+		}
+		days := "?"
+		if value, known := cDep.DaysBehind(at); known {
+			days = fmt.Sprintf("%.0f", value)
+		}
+		rows = append(rows, []string{
+			cDep.Path,
+			cDep.DependencyType(),
+			cDep.Version,
+			latest,
+			days,
+			kind,
+		})
+	}
+	return rows
+}
+
+// replaceRows - one row per replaced dependency
+func replaceRows(report *analysis.Report) [][]string {
+	rows := [][]string{}
+	for _, cReplace := range report.Replaces {
+		if cReplace.Replace == nil {
+			continue
+		}
+		replacement := cReplace.Replace.Path
+		if cReplace.Replace.Version != "" {
+			replacement += "@" + cReplace.Replace.Version
+		}
+		rows = append(rows, []string{cReplace.Path, replacement})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][0] < rows[j][0]
+	})
+	return rows
+}
+
+// writeTable - write dependencies of given analysis as an aligned table for terminals
+func writeTable(out io.Writer, report *analysis.Report, at time.Time) error {
+	if !report.Success {
+		_, err := fmt.Fprintln(out, "no analysis result available")
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "%s (go %s)\n\n", report.Module, report.GoVersion)
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, strings.Join(tableHeader, "\t"))
+	for _, cRow := range tableRows(report, at) {
+		_, _ = fmt.Fprintln(writer, strings.Join(cRow, "\t"))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	replaces := replaceRows(report)
+	if len(replaces) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(out, "\nReplaced modules:")
+	writer = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, cRow := range replaces {
+		_, _ = fmt.Fprintf(writer, "  %s\t=> %s\n", cRow[0], cRow[1])
+	}
+	return writer.Flush()
+}
+
+// markdownCell - escape characters breaking markdown table cells
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+// writeMarkdown - write dependencies of given analysis as markdown, suitable for merge request comments
+func writeMarkdown(out io.Writer, report *analysis.Report, at time.Time) error {
+	if !report.Success {
+		_, err := fmt.Fprintln(out, "**Dependency analysis failed**, no result available.")
+		return err
+	}
+
+	builder := strings.Builder{}
+	rows := tableRows(report, at)
+	outdated := 0
+	for _, cDep := range report.Dependencies {
+		if cDep.Update != nil {
+			outdated++
+		}
+	}
+	fmt.Fprintf(&builder, "### Dependencies of `%s`\n\n", report.Module)
+	fmt.Fprintf(&builder, "Go %s, %d dependencies, %d outdated.\n\n", report.GoVersion, len(report.Dependencies), outdated)
+	builder.WriteString("| Dependency | Type | Current | Latest | Days behind | Update |\n")
+	builder.WriteString("|---|---|---|---|---:|---|\n")
+	for _, cRow := range rows {
+		cells := make([]string, len(cRow))
+		for cIdx, cCell := range cRow {
+			cells[cIdx] = markdownCell(cCell)
+		}
+		cells[0] = "`" + cells[0] + "`"
+		fmt.Fprintf(&builder, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	if replaces := replaceRows(report); len(replaces) != 0 {
+		builder.WriteString("\n#### Replaced modules\n\n")
+		builder.WriteString("| Module | Replacement |\n")
+		builder.WriteString("|---|---|\n")
+		for _, cRow := range replaces {
+			fmt.Fprintf(&builder, "| `%s` | `%s` |\n", markdownCell(cRow[0]), markdownCell(cRow[1]))
+		}
+	}
+	_, err := io.WriteString(out, builder.String())
+	return err
+}
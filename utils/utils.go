@@ -1,9 +1,17 @@
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 // Utility function to close an io.Closer and log errors without returning them
@@ -25,3 +33,74 @@ func RemoveDir(path string) {
 		fmt.Printf("Error removing directory %s: %v", path, err)
 	}
 }
+
+// WriteFileAtomic - write content to given path through a temporary file of the same directory,
+// readers never see a partially written file
+func WriteFileAtomic(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// RunParallel - call fn for each index in [0, count) using at most workers goroutines,
+// remaining indexes are skipped once ctx is done
+func RunParallel(ctx context.Context, workers int, count int, fn func(ctx context.Context, idx int)) {
+	if workers <= 0 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers && i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				fn(ctx, idx)
+			}
+		}()
+	}
+
+	for idx := 0; idx < count; idx++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indexes <- idx:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// RedactURL - hide credentials embedded in url, if any, so that it can be logged or used as a name.
+// Users of http urls are hidden as well since tokens are often given as user
+func RedactURL(rawURL string) string {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.User == nil {
+		return rawURL
+	}
+	if _, ok := target.User.Password(); !ok && strings.HasPrefix(target.Scheme, "http") {
+		target.User = url.User("xxxxx")
+	}
+	return target.Redacted()
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SafeName - file name made of given readable text with unsafe characters replaced, followed by
+// a short hash of key so that distinct keys never share a name
+func SafeName(readable string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return unsafeNameChars.ReplaceAllString(readable, "_") + "-" + hex.EncodeToString(sum[:4])
+}